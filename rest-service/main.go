@@ -1,31 +1,246 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"github.com/julienschmidt/httprouter"
 	"github.com/stackpath/backend-developer-tests/rest-service/pkg/api"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/config"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/grpcapi"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/health"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/server"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/webhook"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io"
 	"log"
-	"net/http"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-var listenAddr = ":8080"
+// printConfig prints the configuration instead of serving
+var printConfig = flag.Bool("print-config", false, "Print the configuration, after every file, environment variable and flag has been applied, as YAML and exit.")
 
 func main() {
-	flag.Parse()
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(auditCommand(os.Args[2:]))
+	}
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatalln("Error loading configuration", err)
+	}
+	if *printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatalln("Error printing configuration", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	if *printConfig {
+		return
+	}
 
 	fmt.Println("SP// Backend Developer Test - RESTful Service")
 	fmt.Println()
 
-	restAPI := api.New()
+	store, err := openStore(cfg.DataFile)
+	if err != nil {
+		log.Fatalln("Error opening people store", err)
+	}
+	// Every change is published for /people/events and webhooks, whether it's made over REST or gRPC
+	events := models.NewEventBus(cfg.Events.History)
+	people := models.NewPublishingStore(store, events)
 
-	router := httprouter.New()
-	router.GET("/people", restAPI.RequestLogger(restAPI.SearchPeople))
-	router.GET("/people/:id", restAPI.RequestLogger(restAPI.GetPerson))
+	accessLog, err := accessLogConfig(cfg.Log)
+	if err != nil {
+		log.Fatalln("Invalid access log configuration", err)
+	}
 
-	log.Fatalln(http.ListenAndServe(listenAddr, router))
+	options := []api.Option{api.WithAccessLog(accessLog), api.WithCacheControl(cfg.CacheControl), api.WithEvents(events)}
+	var grpcOptions []grpcapi.Option
+	if len(cfg.AuthConfig) > 0 {
+		authConfig, err := auth.LoadConfig(cfg.AuthConfig)
+		if err != nil {
+			log.Fatalln("Error loading auth config", err)
+		}
+		authenticator, err := auth.New(authConfig)
+		if err != nil {
+			log.Fatalln("Invalid auth config", err)
+		}
+		options = append(options, api.WithAuthenticator(authenticator))
+		grpcOptions = append(grpcOptions, grpcapi.WithAuthenticator(authenticator))
+	} else {
+		log.Println("No auth config provided, the people API is open to anyone")
+	}
+
+	// Already validated
+	rateLimits, _ := cfg.RateLimits()
+	options = append(options, api.WithRateLimits(rateLimits))
+	grpcOptions = append(grpcOptions, grpcapi.WithRateLimits(rateLimits))
+
+	var auditLog *audit.Log
+	if len(cfg.AuditLog) > 0 {
+		if auditLog, err = audit.Open(cfg.AuditLog); err != nil {
+			log.Fatalln("Error opening audit log", err)
+		}
+		options = append(options, api.WithAuditLog(auditLog))
+		grpcOptions = append(grpcOptions, grpcapi.WithAuditLog(auditLog))
+	}
+
+	restAPI := api.New(people, options...)
+	srv := server.New(restAPI.Router(), cfg.Server())
+
+	// Fail readiness as soon as shutdown starts so load balancers stop sending new requests
+	shutdown := &health.ShutdownFlag{}
+	restAPI.Health().AddReadinessCheck("shutdown", shutdown.Check)
+	srv.RegisterOnShutdown(shutdown.Set)
+	// Event streams never finish by themselves so they would hold up draining until the shutdown timeout
+	srv.RegisterOnShutdown(restAPI.CloseStreams)
+
+	// Drain in-flight requests on SIGINT or SIGTERM
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s\n", sig)
+		cancel()
+	}()
+
+	// gRPC shares the REST API's certificate and client CAs so it is never served in plaintext alongside HTTPS
+	tlsConfig, err := srv.TLSConfig()
+	if err != nil {
+		log.Fatalln("Invalid TLS configuration", err)
+	}
+	stopGRPC, err := serveGRPC(grpcapi.New(people, grpcOptions...), cfg.GRPCListenAddr, tlsConfig, cfg.Timeouts.Shutdown)
+	if err != nil {
+		log.Fatalln("Error starting gRPC server", err)
+	}
+
+	webhooks := webhook.New(events, cfg.Webhooks())
+	webhooks.Start()
+	if endpoints := len(cfg.Events.Webhooks.Endpoints); endpoints > 0 {
+		log.Printf("Delivering change events to %d webhooks\n", endpoints)
+	}
+
+	// Everything is stopped and closed even if the server failed, so nothing in flight is lost
+	runErr := srv.Run(ctx)
+	stopGRPC()
+	webhooks.Stop()
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("Error closing people store", err)
+		}
+	}
+	if auditLog != nil {
+		if err := auditLog.Close(); err != nil {
+			log.Println("Error closing audit log", err)
+		}
+	}
+	if runErr != nil {
+		log.Fatalln("Error running server", runErr)
+	}
+	log.Println("Server stopped")
+}
+
+// openStore opens the file backed store if a data file was provided, otherwise falls back to the sample data in memory
+func openStore(dataFile string) (models.PersonStore, error) {
+	if len(dataFile) == 0 {
+		log.Println("No data file provided, serving the sample people from memory")
+		return models.NewMemoryStore(models.SamplePeople()...), nil
+	}
+
+	log.Printf("Loading people from %s\n", dataFile)
+	return models.OpenFileStore(dataFile)
 }
 
-func init() {
-	flag.StringVar(&listenAddr, "listenAddr", listenAddr, "The address to listen on passed into ListenAndServe.")
+// serveGRPC serves the gRPC API in the background if it has a listen address, over TLS unless tlsConfig is nil,
+// returning a function that stops it gracefully, waiting up to the shutdown timeout for in-flight calls
+func serveGRPC(people *grpcapi.Server, grpcAddr string, tlsConfig *tls.Config, shutdownTimeout time.Duration) (func(), error) {
+	if len(grpcAddr) == 0 {
+		return func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s, %w", grpcAddr, err)
+	}
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := people.GRPCServer(options...)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Println("Error serving gRPC", err)
+		}
+	}()
+	if tlsConfig != nil {
+		log.Printf("Serving gRPC over TLS on %s\n", listener.Addr().String())
+	} else {
+		log.Printf("Serving gRPC on %s\n", listener.Addr().String())
+	}
+
+	return func() {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			grpcServer.Stop()
+		}
+	}, nil
+}
+
+// accessLogConfig builds the access log configuration, opening its file
+func accessLogConfig(logConfig config.Log) (api.AccessLogConfig, error) {
+	config := api.DefaultAccessLogConfig()
+
+	format, err := api.ParseLogFormat(logConfig.Format)
+	if err != nil {
+		return config, err
+	}
+	config.Format = format
+
+	level, err := api.ParseLogLevel(logConfig.Level)
+	if err != nil {
+		return config, err
+	}
+	config.Level = level
+
+	if len(logConfig.AccessLog) > 0 {
+		file, err := os.OpenFile(logConfig.AccessLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return config, fmt.Errorf("error opening access log %s, %w", logConfig.AccessLog, err)
+		}
+		config.Output = file
+	}
+	return config, nil
+}
+
+// auditCommand runs the audit subcommands, returning the exit code
+func auditCommand(args []string) int {
+	if len(args) != 2 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "Usage: rest-service audit verify <audit log file>")
+		return 2
+	}
+
+	result, err := audit.VerifyFile(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Audit log %s failed verification after %d entries: %s\n", args[1], result.Entries, err.Error())
+		return 1
+	}
+	fmt.Printf("Audit log %s verified, %d entries, last hash %s\n", args[1], result.Entries, result.LastHash)
+	return 0
 }
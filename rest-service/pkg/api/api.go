@@ -2,37 +2,129 @@ package api
 
 import (
 	"encoding/json"
-	"github.com/julienschmidt/httprouter"
+	"fmt"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/health"
 	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// maxBodySize is the largest request body accepted by the API
+const maxBodySize = 1 << 20
+
 type API struct {
+	store     models.PersonStore
+	accessLog *accessLogger
+	metrics   *apiMetrics
+	health    *health.Checker
+	// authenticator is nil when authentication is disabled
+	authenticator *auth.Authenticator
+	rateLimits    RateLimitConfig
+	limiters      rateLimiters
+	// auditLog is nil when auditing is disabled
+	auditLog     *audit.Log
+	cacheControl string
+	encodings    *encoding.Registry
+	// exportFormats are the formats people can be streamed in by ExportPeople
+	exportFormats *encoding.Registry
+	// events is nil when the event stream is disabled
+	events       *models.EventBus
+	eventFormats *encoding.Registry
+	// streamsClosed is closed by CloseStreams to end every event stream
+	streamsClosed chan struct{}
+	closeStreams  sync.Once
 }
 
-func New() *API {
-	return &API{}
+// Option configures optional behaviour of the API
+type Option func(api *API)
+
+// WithAccessLog overrides the DefaultAccessLogConfig used by RequestLogger
+func WithAccessLog(config AccessLogConfig) Option {
+	return func(api *API) {
+		api.accessLog = &accessLogger{config: config}
+	}
 }
 
-func (_ *API) RequestLogger(handler httprouter.Handle) httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		log.Printf("[%s] %s: %s\n", r.RemoteAddr, r.Method, r.URL)
-		handler(w, r, ps)
+// New creates an API serving the people in store
+func New(store models.PersonStore, options ...Option) *API {
+	api := &API{
+		store:     store,
+		accessLog: &accessLogger{config: DefaultAccessLogConfig()},
+		encodings: encoding.DefaultRegistry(),
+
+		streamsClosed: make(chan struct{}),
 	}
+	api.exportFormats = encoding.NewRegistry()
+	api.exportFormats.Register(encoding.MediaTypeNDJSON, encoding.MediaTypeNDJSON, encoding.EncoderFunc(encoding.EncodeNDJSON), "application/jsonl")
+	api.exportFormats.Register(encoding.MediaTypeCSV, encoding.MediaTypeCSV+"; charset=utf-8", encoding.EncoderFunc(encoding.EncodeCSV))
+	// StreamEvents writes the events itself, the encoder is only used for errors
+	api.eventFormats = encoding.NewRegistry()
+	api.eventFormats.Register(encoding.MediaTypeEventStream, encoding.MediaTypeEventStream, encoding.EncoderFunc(encoding.EncodeJSON))
+	api.metrics = api.newMetrics()
+	api.health = health.New()
+	api.health.AddReadinessCheck("store", api.checkStore)
+	for _, option := range options {
+		option(api)
+	}
+	return api
+}
+
+// writeResponse Writes the response in the format negotiated from the Accept header with the specified status code
+func (api *API) writeResponse(w http.ResponseWriter, r *http.Request, response interface{}, code int) {
+	format := api.responseFormat(r)
+	api.writeEncoded(w, format, format.ContentType, response, code)
 }
 
-// writeJsonResponse Writes a JSON response with the specified status code
-func (_ *API) writeJsonResponse(w http.ResponseWriter, response interface{}, code int) {
-	w.Header().Set("Content-Type", "application/json")
+func (api *API) writeEncoded(w http.ResponseWriter, format encoding.Format, contentType string, response interface{}, code int) {
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
-	jsonEncoder := json.NewEncoder(w)
-	if err := jsonEncoder.Encode(response); err != nil {
+	if err := format.Encoder.Encode(w, response); err != nil {
 		log.Printf("Error writting response %s\n", err.Error())
 	}
 }
 
-func (api *API) writeErrorResponse(w http.ResponseWriter, message string, code int) {
-	api.writeJsonResponse(w, models.Error{Message: message, Timestamp: time.Now()}, code)
+// decodeJsonBody Decodes the JSON request body into body, writing a 400 response and returning false if it is invalid
+func (api *API) decodeJsonBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		log.Printf("Error decoding request body, %s\n", err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInvalidBody, fmt.Sprintf("Invalid JSON body provided, %s", err.Error()), http.StatusBadRequest)
+		return false
+	}
+	if decoder.More() {
+		api.writeErrorResponse(w, r, models.ProblemInvalidBody, "Invalid JSON body provided, only a single object is allowed", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// problemTypePrefix is prepended to error codes to build the RFC 7807 problem type URI
+const problemTypePrefix = "/problems/"
+
+// writeErrorResponse Writes an RFC 7807 problem details response, as application/problem+json when JSON is negotiated
+func (api *API) writeErrorResponse(w http.ResponseWriter, r *http.Request, errorCode models.ErrorCode, detail string, code int) {
+	api.writeErrorDetailsResponse(w, r, errorCode, detail, nil, code)
+}
+
+// writeErrorDetailsResponse Writes an error response including the individual fields that caused it
+func (api *API) writeErrorDetailsResponse(w http.ResponseWriter, r *http.Request, errorCode models.ErrorCode, detail string, details []models.ErrorDetail, code int) {
+	problem := models.Error{
+		Type:      problemTypePrefix + string(errorCode),
+		Title:     errorCode.Title(),
+		Status:    code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      errorCode,
+		Timestamp: time.Now(),
+		RequestID: RequestIDFromContext(r.Context()),
+		Details:   details,
+	}
+	format := api.responseFormat(r)
+	api.writeEncoded(w, format, format.ProblemContentType(), problem, code)
 }
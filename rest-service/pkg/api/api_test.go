@@ -3,15 +3,17 @@ package api
 import (
 	"encoding/json"
 	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
 	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 func TestAPI_SearchPeople(t *testing.T) {
-	api := New()
+	api := New(models.NewMemoryStore(models.SamplePeople()...))
 	assert.NotNil(t, api)
 
 	t.Run("List All", func(t *testing.T) {
@@ -59,6 +61,20 @@ func TestAPI_SearchPeople(t *testing.T) {
 		assert.Equal(t, "John", result[0].FirstName)
 		assert.Equal(t, "Doe", result[0].LastName)
 	})
+	t.Run("By Phone Number E.164", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?phone_number=%2B18005551414", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result []*models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "+1 (800) 555-1414", result[0].PhoneNumber)
+	})
 	t.Run("No Results Name", func(t *testing.T) {
 		r := httptest.NewRequest(http.MethodGet, "/people?first_name=Bob&last_name=Smith", nil)
 		w := httptest.NewRecorder()
@@ -87,6 +103,89 @@ func TestAPI_SearchPeople(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Len(t, result, 0)
 	})
+	t.Run("Last Name Only", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?last_name=Smith", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result []*models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, "Brian", result[0].FirstName)
+		assert.Equal(t, "Jenny", result[1].FirstName)
+	})
+	t.Run("Combined Prefix And Case Insensitive", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?last_name~=smi*&first_name~=JENNY&phone_number=%2B44%207700%20900077", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result []*models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "000ebe58-b659-422b-ab48-a0d0d40bd8f9", result[0].ID.String())
+	})
+	t.Run("Paged", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?limit=2&offset=2", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result []*models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Equal(t, models.AllPeople()[2:4], result)
+		assert.Equal(t, "5", w.Result().Header.Get("X-Total-Count"))
+		assert.Equal(t, `</people?limit=2&offset=0>; rel="first", `+
+			`</people?limit=2&offset=0>; rel="prev", `+
+			`</people?limit=2&offset=4>; rel="next", `+
+			`</people?limit=2&offset=4>; rel="last"`, w.Result().Header.Get("Link"))
+	})
+	t.Run("Sorted By Name", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?first_name=John&last_name=Doe&sort=-phone_number", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result []*models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, "+1 (800) 555-1414", result[0].PhoneNumber)
+		assert.Equal(t, "+1 (800) 555-1212", result[1].PhoneNumber)
+		assert.Equal(t, "2", w.Result().Header.Get("X-Total-Count"))
+	})
+	t.Run("Invalid Limit", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?limit=0", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+	})
+	t.Run("Invalid Sort", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people?sort=age", nil)
+		w := httptest.NewRecorder()
+
+		api.SearchPeople(w, r, httprouter.ParamsFromContext(r.Context()))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
 	t.Run("Invalid Search Name", func(t *testing.T) {
 		r := httptest.NewRequest(http.MethodGet, "/people?first_name", nil)
 		w := httptest.NewRecorder()
@@ -122,11 +221,12 @@ func TestAPI_SearchPeople(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
 		assert.Nil(t, err)
+		assert.Equal(t, models.ProblemInvalidSearch, result.Code)
 	})
 }
 
 func TestAPI_GetPerson(t *testing.T) {
-	api := New()
+	api := New(models.NewMemoryStore(models.SamplePeople()...))
 	assert.NotNil(t, api)
 
 	t.Run("Exists", func(t *testing.T) {
@@ -155,9 +255,10 @@ func TestAPI_GetPerson(t *testing.T) {
 
 		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
 		assert.Nil(t, err)
+		assert.Equal(t, models.ProblemPersonNotFound, result.Code)
 	})
 	t.Run("Invalid UUID", func(t *testing.T) {
-		r := httptest.NewRequest(http.MethodGet, "/people", nil)
+		r := httptest.NewRequest(http.MethodGet, "/people/this-is-not-a-uuid", nil)
 		w := httptest.NewRecorder()
 
 		api.GetPerson(w, r, []httprouter.Param{{Key: "id", Value: "this-is-not-a-uuid"}})
@@ -167,5 +268,284 @@ func TestAPI_GetPerson(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
 		assert.Nil(t, err)
+		assert.Equal(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+		assert.Equal(t, "/problems/invalid_id", result.Type)
+		assert.Equal(t, "Invalid ID", result.Title)
+		assert.Equal(t, http.StatusBadRequest, result.Status)
+		assert.Equal(t, "Invalid ID provided", result.Detail)
+		assert.Equal(t, "/people/this-is-not-a-uuid", result.Instance)
+		assert.Equal(t, models.ProblemInvalidID, result.Code)
+	})
+}
+
+func TestAPI_CreatePerson(t *testing.T) {
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	api := New(store)
+
+	t.Run("Created", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{"first_name":"Jack","last_name":"Doe","phone_number":"+1 (800) 555-1515"}`))
+		w := httptest.NewRecorder()
+
+		api.CreatePerson(w, r, nil)
+		var result models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.NotEqual(t, uuid.Nil, result.ID)
+		assert.Equal(t, "/people/"+result.ID.String(), w.Result().Header.Get("Location"))
+
+		stored, revision, err := store.FindRevisionByID(result.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, &result, stored)
+		// The validators are the stored revision's, the same as a GET returns
+		assert.Equal(t, etag(revision, false), w.Result().Header.Get("ETag"))
+		assert.Equal(t, revision.UpdatedAt.Format(http.TimeFormat), w.Result().Header.Get("Last-Modified"))
 	})
+	t.Run("ID Provided", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{"id":"df12ce76-767b-4bf0-bccb-816745df9e70","first_name":"Jack"}`))
+		w := httptest.NewRecorder()
+
+		api.CreatePerson(w, r, nil)
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+	})
+	t.Run("Invalid JSON", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{"first_name":`))
+		w := httptest.NewRecorder()
+
+		api.CreatePerson(w, r, nil)
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Equal(t, models.ProblemInvalidBody, result.Code)
+		assert.NotEmpty(t, result.Detail)
+	})
+	t.Run("Unknown Field", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{"first_name":"Jack","middle_name":"J"}`))
+		w := httptest.NewRecorder()
+
+		api.CreatePerson(w, r, nil)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+	t.Run("Invalid Fields", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{"first_name":"Jack","phone_number":"not a number"}`))
+		w := httptest.NewRecorder()
+
+		api.CreatePerson(w, r, nil)
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Equal(t, []models.ErrorDetail{
+			{Field: "last_name", Code: models.CodeRequired, Message: "must not be empty"},
+			{Field: "phone_number", Code: models.CodeInvalidCharacters, Message: "may only contain digits, a leading plus, spaces, hyphens, periods and parentheses"},
+		}, result.Details)
+	})
+}
+
+func TestAPI_ReplacePerson(t *testing.T) {
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	api := New(store)
+
+	t.Run("Replaced", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/people", strings.NewReader(`{"first_name":"Brian","last_name":"Jones","phone_number":"+44 7700 900078"}`))
+		w := httptest.NewRecorder()
+
+		api.ReplacePerson(w, r, []httprouter.Param{{Key: "id", Value: "df12ce76-767b-4bf0-bccb-816745df9e70"}})
+		var result models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Equal(t, "df12ce76-767b-4bf0-bccb-816745df9e70", result.ID.String())
+		assert.Equal(t, "Jones", result.LastName)
+
+		stored, err := store.FindByID(result.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "+44 7700 900078", stored.PhoneNumber)
+	})
+	t.Run("Mismatched ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/people", strings.NewReader(`{"id":"000ebe58-b659-422b-ab48-a0d0d40bd8f9","first_name":"Brian"}`))
+		w := httptest.NewRecorder()
+
+		api.ReplacePerson(w, r, []httprouter.Param{{Key: "id", Value: "df12ce76-767b-4bf0-bccb-816745df9e70"}})
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+	t.Run("Does Not Exist", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/people", strings.NewReader(`{"first_name":"Brian","last_name":"Jones","phone_number":"+44 7700 900078"}`))
+		w := httptest.NewRecorder()
+
+		api.ReplacePerson(w, r, []httprouter.Param{{Key: "id", Value: "df12ce76-767b-4bf0-bccb-816745df9e71"}})
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+		assert.Nil(t, err)
+	})
+}
+
+func TestAPI_UpdatePerson(t *testing.T) {
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	api := New(store)
+
+	t.Run("Updated", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/people", strings.NewReader(`{"phone_number":"+1 (800) 555-9999"}`))
+		w := httptest.NewRecorder()
+
+		api.UpdatePerson(w, r, []httprouter.Param{{Key: "id", Value: "81eb745b-3aae-400b-959f-748fcafafd81"}})
+		var result models.Person
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Equal(t, "John", result.FirstName)
+		assert.Equal(t, "Doe", result.LastName)
+		assert.Equal(t, "+1 (800) 555-9999", result.PhoneNumber)
+	})
+	t.Run("Invalid Fields", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/people", strings.NewReader(`{"first_name":""}`))
+		w := httptest.NewRecorder()
+
+		api.UpdatePerson(w, r, []httprouter.Param{{Key: "id", Value: "81eb745b-3aae-400b-959f-748fcafafd81"}})
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result.Details, 1)
+		assert.Equal(t, "first_name", result.Details[0].Field)
+	})
+	t.Run("Does Not Exist", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/people", strings.NewReader(`{"first_name":"Jack"}`))
+		w := httptest.NewRecorder()
+
+		api.UpdatePerson(w, r, []httprouter.Param{{Key: "id", Value: "81eb745b-3aae-400b-959f-748fcafafd82"}})
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+	t.Run("Invalid UUID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/people", strings.NewReader(`{"first_name":"Jack"}`))
+		w := httptest.NewRecorder()
+
+		api.UpdatePerson(w, r, []httprouter.Param{{Key: "id", Value: "this-is-not-a-uuid"}})
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestAPI_DeletePerson(t *testing.T) {
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	api := New(store)
+
+	t.Run("Deleted", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/people", nil)
+		w := httptest.NewRecorder()
+
+		api.DeletePerson(w, r, []httprouter.Param{{Key: "id", Value: "000ebe58-b659-422b-ab48-a0d0d40bd8f9"}})
+
+		assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		_, err := store.FindByID(uuid.Must(uuid.FromString("000ebe58-b659-422b-ab48-a0d0d40bd8f9")))
+		assert.ErrorIs(t, err, models.ErrPersonNotFound)
+	})
+	t.Run("Does Not Exist", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/people", nil)
+		w := httptest.NewRecorder()
+
+		api.DeletePerson(w, r, []httprouter.Param{{Key: "id", Value: "000ebe58-b659-422b-ab48-a0d0d40bd8f9"}})
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+		assert.Nil(t, err)
+	})
+}
+
+func TestAPI_FuzzySearchPeople(t *testing.T) {
+	api := New(models.NewMemoryStore(models.SamplePeople()...))
+
+	t.Run("Found", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people/search?q=jeny+smiht", nil)
+		w := httptest.NewRecorder()
+
+		api.FuzzySearchPeople(w, r, nil)
+		var result []map[string]interface{}
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "000ebe58-b659-422b-ab48-a0d0d40bd8f9", result[0]["id"])
+		assert.Equal(t, "Jenny", result[0]["first_name"])
+		assert.InDelta(t, 0.8, result[0]["score"], 0.2)
+		assert.Equal(t, "1", w.Result().Header.Get("X-Total-Count"))
+	})
+	t.Run("Paged", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people/search?q=doe&limit=2&offset=2", nil)
+		w := httptest.NewRecorder()
+
+		api.FuzzySearchPeople(w, r, nil)
+		var result []models.ScoredPerson
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "+1 (800) 555-1414", result[0].PhoneNumber)
+		assert.Equal(t, "3", w.Result().Header.Get("X-Total-Count"))
+	})
+	t.Run("Missing Query", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people/search?q=+", nil)
+		w := httptest.NewRecorder()
+
+		api.FuzzySearchPeople(w, r, nil)
+		var result models.Error
+		err := json.NewDecoder(w.Result().Body).Decode(&result)
+		_ = w.Result().Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Nil(t, err)
+	})
+	t.Run("Sorted", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/people/search?q=doe&sort=last_name", nil)
+		w := httptest.NewRecorder()
+
+		api.FuzzySearchPeople(w, r, nil)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestSubroutes(t *testing.T) {
+	called := ""
+	handler := Subroutes("id", map[string]httprouter.Handle{
+		"search": func(http.ResponseWriter, *http.Request, httprouter.Params) { called = "search" },
+	}, func(http.ResponseWriter, *http.Request, httprouter.Params) { called = "fallback" })
+
+	router := httprouter.New()
+	router.GET("/people/:id", handler)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/people/search", nil))
+	assert.Equal(t, "search", called)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/people/df12ce76-767b-4bf0-bccb-816745df9e70", nil))
+	assert.Equal(t, "fallback", called)
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/server"
+	"log"
+	"net/http"
+)
+
+// WithAuditLog records every read and change of people to auditLog. Requests that can't be recorded fail with a 500,
+// reads before any people are sent and changes after they are applied, as recording a change first would audit changes
+// that then fail. Those failures are counted by the audit_failures_total metric.
+func WithAuditLog(auditLog *audit.Log) Option {
+	return func(api *API) {
+		api.auditLog = auditLog
+	}
+}
+
+// recordAudit audits the request, writing a 500 response and returning false if it couldn't be recorded
+func (api *API) recordAudit(w http.ResponseWriter, r *http.Request, action string, ids []uuid.UUID, before, after *models.Person) bool {
+	if err := api.audit(r, action, ids, before, after); err != nil {
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to record the request in the audit log.", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// audit records that the caller performed action on the people with ids. before and after are the state of a changed
+// person and may be nil. It must be called before the people are redacted so the trail has the real values.
+func (api *API) audit(r *http.Request, action string, ids []uuid.UUID, before, after *models.Person) error {
+	if api.auditLog == nil {
+		return nil
+	}
+
+	entry := api.auditEntry(r, action, ids)
+	var err error
+	if entry.Before, err = auditValue(before); err == nil {
+		entry.After, err = auditValue(after)
+	}
+	return api.recordEntry(entry, err)
+}
+
+// auditChanges records that the caller changed every person in changes with action, in a single entry listing each
+// person before and after
+func (api *API) auditChanges(r *http.Request, action string, changes []personChange) error {
+	if api.auditLog == nil {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(changes))
+	for _, change := range changes {
+		ids = append(ids, change.after.ID)
+	}
+	entry := api.auditEntry(r, action, ids)
+	entry.Changes = make([]audit.Change, 0, len(changes))
+	var err error
+	for _, change := range changes {
+		recorded := audit.Change{Resource: change.after.ID.String()}
+		if recorded.Before, err = auditValue(change.before); err == nil {
+			recorded.After, err = auditValue(change.after)
+		}
+		if err != nil {
+			break
+		}
+		entry.Changes = append(entry.Changes, recorded)
+	}
+	return api.recordEntry(entry, err)
+}
+
+// personChange is a person before and after a change, before is nil if the change created them
+type personChange struct {
+	before *models.Person
+	after  *models.Person
+}
+
+// auditEntry starts an entry recording that the caller performed action on the people with ids
+func (api *API) auditEntry(r *http.Request, action string, ids []uuid.UUID) audit.Entry {
+	entry := audit.Entry{
+		Actor:      "anonymous",
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  RequestIDFromContext(r.Context()),
+		Action:     action,
+		Resources:  make([]string, 0, len(ids)),
+	}
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		entry.Actor = principal.Method + ":" + principal.Subject
+	} else if subject, ok := server.ClientSubject(r); ok {
+		entry.Actor = "tls:" + subject.String()
+	}
+	for _, id := range ids {
+		entry.Resources = append(entry.Resources, id.String())
+	}
+	return entry
+}
+
+// recordEntry records entry unless building it failed with err, counting the failure if it couldn't be recorded
+func (api *API) recordEntry(entry audit.Entry, err error) error {
+	if err == nil {
+		err = api.auditLog.Record(entry)
+	}
+	if err != nil {
+		log.Printf("Error recording %s of %v in audit log, %s\n", entry.Action, entry.Resources, err.Error())
+		api.metrics.auditFailures.Inc(entry.Action)
+	}
+	return err
+}
+
+// recordAuditPeople audits that the caller performed action on every person, writing a 500 response and returning
+// false if it couldn't be recorded
+func (api *API) recordAuditPeople(w http.ResponseWriter, r *http.Request, action string, people []*models.Person) bool {
+	ids := make([]uuid.UUID, 0, len(people))
+	for _, person := range people {
+		ids = append(ids, person.ID)
+	}
+	return api.recordAudit(w, r, action, ids, nil, nil)
+}
+
+func auditValue(person *models.Person) (json.RawMessage, error) {
+	if person == nil {
+		return nil, nil
+	}
+	return json.Marshal(person)
+}
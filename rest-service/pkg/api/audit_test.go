@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPI_Audit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	assert.Nil(t, err)
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead, ScopeWrite}},
+	}})
+	assert.Nil(t, err)
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuthenticator(authenticator), WithAuditLog(auditLog))
+	router := api.Router()
+
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodGet, "/people/81eb745b-3aae-400b-959f-748fcafafd81", ""},
+		{http.MethodGet, "/people?last_name=Smith", ""},
+		{http.MethodGet, "/people/search?q=jane", ""},
+		{http.MethodPatch, "/people/81eb745b-3aae-400b-959f-748fcafafd81", `{"last_name":"Roe"}`},
+		{http.MethodDelete, "/people/5b81b629-9026-450d-8e46-da4f8c7bd513", ""},
+		{http.MethodPost, "/people", `{"first_name":"Jack","last_name":"Doe","phone_number":"+1 (800) 555-1515"}`},
+		{http.MethodDelete, "/people/5b81b629-9026-450d-8e46-da4f8c7bd513", ""},
+	} {
+		r := httptest.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		r.Header.Set(auth.APIKeyHeader, "reader-key")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	assert.Nil(t, auditLog.Close())
+
+	result, err := audit.VerifyFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(6), result.Entries)
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	entries := make([]audit.Entry, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	assert.Equal(t, "api_key:reader", entries[0].Actor)
+	assert.Equal(t, "192.0.2.1:1234", entries[0].RemoteAddr)
+	assert.NotEmpty(t, entries[0].RequestID)
+	assert.Equal(t, audit.ActionRead, entries[0].Action)
+	assert.Equal(t, []string{"81eb745b-3aae-400b-959f-748fcafafd81"}, entries[0].Resources)
+
+	assert.Equal(t, audit.ActionSearch, entries[1].Action)
+	assert.Len(t, entries[1].Resources, 2)
+	assert.Equal(t, audit.ActionSearch, entries[2].Action)
+	assert.Equal(t, []string{"5b81b629-9026-450d-8e46-da4f8c7bd513"}, entries[2].Resources)
+
+	// Changes are recorded unmasked even though the caller can't see phone numbers
+	assert.Equal(t, audit.ActionUpdate, entries[3].Action)
+	assert.JSONEq(t, `{"id":"81eb745b-3aae-400b-959f-748fcafafd81","first_name":"John","last_name":"Doe","phone_number":"+1 (800) 555-1212"}`, string(entries[3].Before))
+	assert.JSONEq(t, `{"id":"81eb745b-3aae-400b-959f-748fcafafd81","first_name":"John","last_name":"Roe","phone_number":"+1 (800) 555-1212"}`, string(entries[3].After))
+
+	assert.Equal(t, audit.ActionDelete, entries[4].Action)
+	assert.Contains(t, string(entries[4].Before), "Jane")
+	assert.Nil(t, entries[4].After)
+
+	assert.Equal(t, audit.ActionCreate, entries[5].Action)
+	assert.Contains(t, string(entries[5].After), "Jack")
+}
+
+func TestAPI_AuditBulk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	assert.Nil(t, err)
+	router := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuditLog(auditLog)).Router()
+
+	r := httptest.NewRequest(http.MethodPost, "/people:import?upsert=true", strings.NewReader(
+		`{"id":"0b4ec3b6-2b84-4b7d-b5e2-0c1b9c9c8e01","first_name":"Ann","last_name":"Lee","phone_number":"+1 (800) 555-2000"}`+"\n"+
+			`{"id":"81eb745b-3aae-400b-959f-748fcafafd81","first_name":"John","last_name":"Roe","phone_number":"+1 (800) 555-1212"}`+"\n"+
+			`{"first_name":""}`+"\n"))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/people:export", nil))
+	assert.Nil(t, auditLog.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	// One entry per request however many people it covers
+	var imported, exported audit.Entry
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &imported))
+	assert.Equal(t, audit.ActionImport, imported.Action)
+	assert.Equal(t, []string{"0b4ec3b6-2b84-4b7d-b5e2-0c1b9c9c8e01", "81eb745b-3aae-400b-959f-748fcafafd81"}, imported.Resources)
+	// Each person imported is recorded before and after, with the value an upsert replaced
+	if assert.Len(t, imported.Changes, 2) {
+		assert.Equal(t, "0b4ec3b6-2b84-4b7d-b5e2-0c1b9c9c8e01", imported.Changes[0].Resource)
+		assert.Nil(t, imported.Changes[0].Before)
+		assert.Contains(t, string(imported.Changes[0].After), `"last_name":"Lee"`)
+		assert.Equal(t, "81eb745b-3aae-400b-959f-748fcafafd81", imported.Changes[1].Resource)
+		assert.Contains(t, string(imported.Changes[1].Before), `"last_name":"Doe"`)
+		assert.Contains(t, string(imported.Changes[1].After), `"last_name":"Roe"`)
+	}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &exported))
+	assert.Equal(t, audit.ActionExport, exported.Action)
+	assert.Len(t, exported.Resources, len(models.SamplePeople())+1)
+}
+
+func TestAPI_AuditClientCertificate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	assert.Nil(t, err)
+	router := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuditLog(auditLog)).Router()
+
+	r := httptest.NewRequest(http.MethodGet, "/people/81eb745b-3aae-400b-959f-748fcafafd81", nil)
+	client := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"StackPath"}}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client}}}
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Nil(t, auditLog.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	var entry audit.Entry
+	assert.Nil(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "tls:CN=billing,O=StackPath", entry.Actor)
+}
+
+func TestAPI_AuditFailure(t *testing.T) {
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	assert.Nil(t, err)
+	// Every entry fails to be recorded once the file is closed
+	assert.Nil(t, auditLog.Close())
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	router := New(store, WithAccessLog(AccessLogConfig{Output: ioutil.Discard}), WithAuditLog(auditLog)).Router()
+
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodGet, "/people/81eb745b-3aae-400b-959f-748fcafafd81", ""},
+		{http.MethodGet, "/people?last_name=Smith", ""},
+		{http.MethodGet, "/people:export", ""},
+		{http.MethodDelete, "/people/5b81b629-9026-450d-8e46-da4f8c7bd513", ""},
+	} {
+		t.Run(request.method+" "+request.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(request.method, request.path, strings.NewReader(request.body)))
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.NotContains(t, w.Body.String(), "John")
+		})
+	}
+	t.Run("Import", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/people:import", strings.NewReader(`{"first_name":"Ann","last_name":"Lee","phone_number":"+1 (800) 555-2000"}`))
+		r.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// The person was already imported so the report still says so
+		assert.Equal(t, http.StatusOK, w.Code)
+		var report models.ImportReport
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, 1, report.Imported)
+		assert.False(t, report.Complete)
+		if assert.Len(t, report.Errors, 1) {
+			assert.Equal(t, 1, report.Errors[0].Record)
+			assert.Equal(t, models.ProblemInternal, report.Errors[0].Code)
+			assert.Contains(t, report.Errors[0].Message, "couldn't be recorded in the audit log")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `audit_failures_total{action="read"} 1`)
+	assert.Contains(t, w.Body.String(), `audit_failures_total{action="delete"} 1`)
+	assert.Contains(t, w.Body.String(), `audit_failures_total{action="import"} 1`)
+}
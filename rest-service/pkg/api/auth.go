@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"log"
+	"net/http"
+)
+
+const (
+	// ScopeRead allows reading people, with phone numbers masked
+	ScopeRead = "people:read"
+	// ScopeWrite allows creating, updating and deleting people
+	ScopeWrite = "people:write"
+	// ScopePII allows seeing and searching by unmasked phone numbers
+	ScopePII = "people:pii"
+)
+
+// WithAuthenticator requires every people route to be called with credentials accepted by authenticator. Without it
+// the API is open to anyone.
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(api *API) {
+		api.authenticator = authenticator
+	}
+}
+
+// Authenticate rejects requests without valid credentials and makes the principal available to handler through
+// auth.PrincipalFromContext. Missing or invalid credentials get a 401 and disabled credentials a 403, addresses that
+// have failed to authenticate too often get a 429 whatever their credentials.
+func (api *API) Authenticate(handler httprouter.Handle) httprouter.Handle {
+	if api.authenticator == nil {
+		return handler
+	}
+	failures := api.authFailureLimiter()
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		// Checked before authenticating so credentials can't be guessed once the address is limited
+		client := remoteAddrKey(r)
+		if result := failures.Peek(client); !result.Allowed {
+			api.writeRateLimited(w, r, "Too many failed authentication attempts", result)
+			return
+		}
+
+		principal, err := api.authenticator.Authenticate(r)
+		if err != nil {
+			log.Printf("Error authenticating request %s, %s\n", RequestIDFromContext(r.Context()), err.Error())
+			if !errors.Is(err, auth.ErrMissingCredentials) {
+				failures.Allow(client)
+			}
+			if errors.Is(err, auth.ErrDisabled) {
+				api.writeErrorResponse(w, r, models.ProblemCredentialsDisabled, "The provided credentials have been disabled", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="people"`)
+			if errors.Is(err, auth.ErrMissingCredentials) {
+				api.writeErrorResponse(w, r, models.ProblemAuthenticationRequired, "Authentication required, provide an X-API-Key header or a bearer token", http.StatusUnauthorized)
+				return
+			}
+			api.writeErrorResponse(w, r, models.ProblemInvalidCredentials, "Invalid credentials provided", http.StatusUnauthorized)
+			return
+		}
+
+		handler(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)), ps)
+	}
+}
+
+// Authorize rejects requests from principals that weren't granted scope with a 403. Every scope is allowed when
+// authentication is disabled.
+func (api *API) Authorize(scope string, handler httprouter.Handle) httprouter.Handle {
+	if api.authenticator == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !api.hasScope(r, scope) {
+			api.writeErrorResponse(w, r, models.ProblemInsufficientScope, fmt.Sprintf("The %s scope is required", scope), http.StatusForbidden)
+			return
+		}
+		handler(w, r, ps)
+	}
+}
+
+// hasScope reports whether the caller was granted scope, always true when authentication is disabled
+func (api *API) hasScope(r *http.Request, scope string) bool {
+	if api.authenticator == nil {
+		return true
+	}
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	return ok && principal.HasScope(scope)
+}
+
+// requirePII writes a 403 response and returns false if the caller needs the PII scope for the request and doesn't
+// have it
+func (api *API) requirePII(w http.ResponseWriter, r *http.Request, needed bool, reason string) bool {
+	if !needed || api.hasScope(r, ScopePII) {
+		return true
+	}
+	api.writeErrorResponse(w, r, models.ProblemInsufficientScope, fmt.Sprintf("The %s scope is required to %s", ScopePII, reason), http.StatusForbidden)
+	return false
+}
+
+// redact masks the phone numbers of people when the caller doesn't have the PII scope
+func (api *API) redact(r *http.Request, people ...*models.Person) {
+	if api.hasScope(r, ScopePII) {
+		return
+	}
+	for _, person := range people {
+		person.PhoneNumber = models.MaskPhoneNumber(person.PhoneNumber)
+	}
+}
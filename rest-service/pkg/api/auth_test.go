@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_Authenticate(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{
+		APIKeys: []auth.APIKey{
+			{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead}},
+			{Name: "retired", Key: "retired-key", Disabled: true},
+		},
+		JWT: auth.JWTConfig{Secret: "test-secret"},
+	})
+	assert.Nil(t, err)
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}), WithAuthenticator(authenticator))
+	router := api.Router()
+
+	request := func(header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/people/81eb745b-3aae-400b-959f-748fcafafd81", nil)
+		if len(header) > 0 {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("API Key", func(t *testing.T) {
+		w := request(auth.APIKeyHeader, "reader-key")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("Bearer Token", func(t *testing.T) {
+		token, err := auth.SignToken(auth.Claims{Subject: "jane", ExpiresAt: time.Now().Add(time.Minute).Unix(), Scope: ScopeRead}, []byte("test-secret"))
+		assert.Nil(t, err)
+
+		w := request("Authorization", "Bearer "+token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("Missing Credentials", func(t *testing.T) {
+		w := request("", "")
+
+		var response models.Error
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&response))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="people"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, models.ProblemAuthenticationRequired, response.Code)
+		assert.Contains(t, response.Detail, "Authentication required")
+	})
+	t.Run("Invalid Credentials", func(t *testing.T) {
+		w := request(auth.APIKeyHeader, "guess")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("Disabled Credentials", func(t *testing.T) {
+		w := request(auth.APIKeyHeader, "retired-key")
+
+		var response models.Error
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&response))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, models.ProblemCredentialsDisabled, response.Code)
+	})
+	t.Run("Probes Are Public", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("Principal In Context", func(t *testing.T) {
+		var principal *auth.Principal
+		handler := api.Authenticate(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			principal, _ = auth.PrincipalFromContext(r.Context())
+		})
+		r := httptest.NewRequest(http.MethodGet, "/people", nil)
+		r.Header.Set(auth.APIKeyHeader, "reader-key")
+		handler(httptest.NewRecorder(), r, nil)
+
+		assert.Equal(t, &auth.Principal{Subject: "reader", Method: auth.MethodAPIKey, Scopes: []string{ScopeRead}}, principal)
+	})
+}
+
+func TestAPI_Authorize(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead}},
+		{Name: "writer", Key: "writer-key", Scopes: []string{ScopeRead, ScopeWrite}},
+		{Name: "support", Key: "support-key", Scopes: []string{ScopeRead, ScopePII}},
+	}})
+	assert.Nil(t, err)
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}), WithAuthenticator(authenticator))
+	router := api.Router()
+
+	request := func(method, path, key string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	decodePeople := func(w *httptest.ResponseRecorder) []models.Person {
+		var people []models.Person
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&people))
+		_ = w.Result().Body.Close()
+		return people
+	}
+
+	t.Run("Missing Write Scope", func(t *testing.T) {
+		w := request(http.MethodDelete, "/people/81eb745b-3aae-400b-959f-748fcafafd81", "reader-key", "")
+
+		var response models.Error
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&response))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, models.ProblemInsufficientScope, response.Code)
+		assert.Equal(t, "The people:write scope is required", response.Detail)
+	})
+	t.Run("Write Scope", func(t *testing.T) {
+		w := request(http.MethodPatch, "/people/81eb745b-3aae-400b-959f-748fcafafd81", "writer-key", `{"last_name":"Doe"}`)
+
+		var person models.Person
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&person))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "+1 (800) ***-**12", person.PhoneNumber)
+	})
+	t.Run("Masked Without PII Scope", func(t *testing.T) {
+		w := request(http.MethodGet, "/people/df12ce76-767b-4bf0-bccb-816745df9e70", "reader-key", "")
+
+		var person models.Person
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&person))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "+44 7700 ****77", person.PhoneNumber)
+
+		for _, person := range decodePeople(request(http.MethodGet, "/people?last_name=Smith", "reader-key", "")) {
+			assert.Contains(t, person.PhoneNumber, "****")
+		}
+	})
+	t.Run("Unmasked With PII Scope", func(t *testing.T) {
+		people := decodePeople(request(http.MethodGet, "/people?first_name=Brian", "support-key", ""))
+
+		assert.Len(t, people, 1)
+		assert.Equal(t, "+44 7700 900077", people[0].PhoneNumber)
+	})
+	t.Run("Phone Number Search Requires PII Scope", func(t *testing.T) {
+		for _, path := range []string{
+			"/people?phone_number=%2B44%207700%20900077",
+			"/people?sort=phone_number",
+			"/people/search?q=7700",
+		} {
+			assert.Equal(t, http.StatusForbidden, request(http.MethodGet, path, "reader-key", "").Code, path)
+			assert.Equal(t, http.StatusOK, request(http.MethodGet, path, "support-key", "").Code, path)
+		}
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/people/search?q=smith", "reader-key", "").Code)
+	})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// importAuditBatch is how many imported people ImportPeople records in each audit entry
+const importAuditBatch = 100
+
+// exportPageSize is how many people ExportPeople copies from the store, audits and writes to the client at a time
+const exportPageSize = 100
+
+// importMediaTypes maps the content types ImportPeople accepts to the stream they are decoded as
+var importMediaTypes = map[string]string{
+	encoding.MediaTypeNDJSON: encoding.MediaTypeNDJSON,
+	"application/jsonl":      encoding.MediaTypeNDJSON,
+	encoding.MediaTypeCSV:    encoding.MediaTypeCSV,
+}
+
+// ImportPeople creates a person for every record of an NDJSON or CSV body, one record at a time so bodies of any size
+// can be imported. Each record is validated on its own and the response reports why any were rejected. Records with
+// an ID keep it, and with `?upsert=true` replace the existing person with that ID rather than failing. The people
+// imported are audited in batches with their values before and after, and the import stops if a batch can't be.
+func (api *API) ImportPeople(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	upsert := false
+	if value := r.URL.Query().Get("upsert"); len(value) > 0 {
+		var err error
+		if upsert, err = strconv.ParseBool(value); err != nil {
+			api.writeErrorResponse(w, r, models.ProblemInvalidParameter, fmt.Sprintf("Invalid upsert parameter provided, %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	streamType, ok := importMediaTypes[mediaType]
+	if !ok {
+		api.writeErrorResponse(w, r, models.ProblemUnsupportedMediaType, fmt.Sprintf("People can only be imported from %s or %s", encoding.MediaTypeNDJSON, encoding.MediaTypeCSV), http.StatusUnsupportedMediaType)
+		return
+	}
+	decoder, err := encoding.NewStreamDecoder(streamType, r.Body)
+	if err != nil {
+		log.Printf("Error creating %s decoder, %s\n", streamType, err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to import people.", http.StatusInternalServerError)
+		return
+	}
+
+	report := models.ImportReport{Complete: true, Errors: make([]models.ImportError, 0)}
+	changes := make([]personChange, 0, importAuditBatch)
+	// auditChanges records the batch of changes, stopping the import at record if they can't be
+	auditChanges := func(record int) bool {
+		if len(changes) == 0 {
+			return true
+		}
+		err := api.auditChanges(r, audit.ActionImport, changes)
+		if err != nil {
+			// The people are already imported so the report is still sent to say which
+			report.Stop(record, models.ProblemInternal, fmt.Sprintf("The last %d people imported, up to this record, couldn't be recorded in the audit log so the import was stopped", len(changes)))
+		}
+		changes = changes[:0]
+		return err == nil
+	}
+	lastRecord := 0
+	for record := 1; ; record++ {
+		var person models.Person
+		err := decoder.Decode(&person)
+		if err == io.EOF {
+			break
+		}
+		lastRecord = record
+
+		var recordErr *encoding.RecordError
+		if errors.As(err, &recordErr) {
+			report.Fail(record, models.ProblemInvalidBody, fmt.Sprintf("Invalid record, %s", recordErr.Err.Error()), nil)
+			continue
+		} else if err != nil {
+			// The rest of the stream can't be read so nothing after this record is imported
+			report.Complete = false
+			report.Fail(record, models.ProblemInvalidBody, fmt.Sprintf("Unable to read the rest of the body, %s", err.Error()), nil)
+			break
+		}
+
+		if err := person.Validate(); err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				report.Fail(record, models.ProblemValidationFailed, "Invalid person provided", validationErr.Details)
+			} else {
+				report.Fail(record, models.ProblemValidationFailed, fmt.Sprintf("Invalid person provided, %s", err.Error()), nil)
+			}
+			continue
+		}
+
+		before, code, message := api.importPerson(&person, upsert)
+		if len(code) > 0 {
+			report.Fail(record, code, message, nil)
+			continue
+		}
+		report.Imported++
+		changes = append(changes, personChange{before: before, after: &person})
+		if len(changes) == importAuditBatch && !auditChanges(record) {
+			break
+		}
+	}
+
+	// Including the people imported before the stream failed
+	auditChanges(lastRecord)
+	api.writeResponse(w, r, &report, http.StatusOK)
+}
+
+// importPerson creates person, or replaces the person with its ID when upserting. Returns the person it replaced, or
+// the code and message of the failure if it wasn't imported.
+func (api *API) importPerson(person *models.Person, upsert bool) (*models.Person, models.ErrorCode, string) {
+	_, err := api.store.Create(person)
+	if err == nil {
+		return nil, "", ""
+	} else if !errors.Is(err, models.ErrPersonExists) {
+		log.Printf("Error importing person %s, %s\n", person.ID.String(), err.Error())
+		return nil, models.ProblemInternal, "Unable to create person."
+	} else if !upsert {
+		return nil, models.ProblemPersonExists, fmt.Sprintf("Person %s already exists", person.ID.String())
+	}
+
+	// Only the revision that was read is replaced so the audit log has exactly what the import overwrote
+	before, revision, err := api.store.FindRevisionByID(person.ID)
+	if err == nil {
+		_, err = api.store.UpdateRevision(person, revision.Version)
+	}
+	if errors.Is(err, models.ErrPersonNotFound) {
+		// Deleted since it was found to exist
+		return nil, models.ProblemPersonNotFound, fmt.Sprintf("Person %s was deleted during the import", person.ID.String())
+	} else if errors.Is(err, models.ErrRevisionMismatch) {
+		return nil, models.ProblemPreconditionFailed, fmt.Sprintf("Person %s was changed during the import", person.ID.String())
+	} else if err != nil {
+		log.Printf("Error importing person %s, %s\n", person.ID.String(), err.Error())
+		return nil, models.ProblemInternal, "Unable to update person."
+	}
+	return before, "", ""
+}
+
+// ExportPeople streams every person as NDJSON or CSV, a page at a time so the client receives them while the rest are
+// encoded and the store is never locked while writing to the client. Each page is audited before it is sent.
+func (api *API) ExportPeople(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	format := api.responseFormat(r)
+	flusher, _ := w.(http.Flusher)
+	var encoder encoding.StreamEncoder
+	// export sends a page of people, starting the response with the first
+	export := func(people []*models.Person) error {
+		ids := make([]uuid.UUID, 0, len(people))
+		for _, person := range people {
+			ids = append(ids, person.ID)
+		}
+		if err := api.audit(r, audit.ActionExport, ids, nil, nil); err != nil {
+			return err
+		}
+
+		if encoder == nil {
+			w.Header().Set("Content-Type", format.ContentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="people%s"`, exportExtension(format.MediaType)))
+			var err error
+			if encoder, err = encoding.NewStreamEncoder(format.MediaType, w, &models.Person{}); err != nil {
+				return fmt.Errorf("error creating %s encoder, %w", format.MediaType, err)
+			}
+		}
+		api.redact(r, people...)
+		for _, person := range people {
+			if err := encoder.Encode(person); err != nil {
+				return fmt.Errorf("error exporting person %s, %w", person.ID.String(), err)
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return fmt.Errorf("error flushing people export, %w", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	err := api.store.Each(exportPageSize, export)
+	if err == nil && encoder == nil {
+		// An empty export is still audited and gets a CSV header
+		err = export(nil)
+	}
+	if err != nil {
+		log.Printf("Error exporting people, %s\n", err.Error())
+		if encoder == nil {
+			w.Header().Del("Content-Disposition")
+			api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to export people.", http.StatusInternalServerError)
+		}
+		// Otherwise the status has already been sent so the client only sees a truncated body
+	}
+}
+
+func exportExtension(mediaType string) string {
+	if mediaType == encoding.MediaTypeCSV {
+		return ".csv"
+	}
+	return ".ndjson"
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPI_ImportPeople(t *testing.T) {
+	store := models.NewMemoryStore(models.SamplePeople()...)
+	router := New(store, WithAccessLog(AccessLogConfig{Output: ioutil.Discard})).Router()
+
+	request := func(path, contentType, body string) (*httptest.ResponseRecorder, models.ImportReport) {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var report models.ImportReport
+		if w.Code == http.StatusOK {
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&report))
+		}
+		return w, report
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		w, report := request("/people:import", "application/x-ndjson",
+			`{"id":"0b4ec3b6-2b84-4b7d-b5e2-0c1b9c9c8e01","first_name":"Ann","last_name":"Lee","phone_number":"+1 (800) 555-2000"}`+"\n"+
+				`{"first_name":"Bob","last_name":"Lee","phone_number":"+1 (800) 555-2001"}`+"\n")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.ImportReport{Imported: 2, Complete: true, Errors: []models.ImportError{}}, report)
+		person, err := store.FindByID(uuid.Must(uuid.FromString("0b4ec3b6-2b84-4b7d-b5e2-0c1b9c9c8e01")))
+		assert.Nil(t, err)
+		assert.Equal(t, "Ann", person.FirstName)
+	})
+	t.Run("CSV", func(t *testing.T) {
+		w, report := request("/people:import", "text/csv; charset=utf-8",
+			"first_name,last_name,phone_number\nCal,Lee,+1 (800) 555-2002\nDee,Lee,+1 (800) 555-2003\n")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, report.Imported)
+		assert.True(t, report.Complete)
+		people, _ := store.Search(models.Query{Filters: []models.Filter{{Field: models.FieldFirstName, Value: "Dee"}}})
+		assert.Len(t, people, 1)
+	})
+	t.Run("Per Record Errors", func(t *testing.T) {
+		w, report := request("/people:import", "application/x-ndjson", "{\"first_name\":\"Eve\",\"last_name\":\"Lee\",\"phone_number\":\"+1 (800) 555-2004\"}\n"+
+			"not json\n"+
+			"{\"first_name\":\"\",\"last_name\":\"Lee\",\"phone_number\":\"+1 (800) 555-2005\"}\n"+
+			"{\"id\":\"81eb745b-3aae-400b-959f-748fcafafd81\",\"first_name\":\"John\",\"last_name\":\"Roe\",\"phone_number\":\"+1 (800) 555-1212\"}\n")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, report.Imported)
+		assert.Equal(t, 3, report.Failed)
+		assert.True(t, report.Complete)
+		assert.Len(t, report.Errors, 3)
+		assert.Equal(t, models.ImportError{Record: 2, Code: models.ProblemInvalidBody, Message: report.Errors[0].Message}, report.Errors[0])
+		assert.Equal(t, 3, report.Errors[1].Record)
+		assert.Equal(t, models.ProblemValidationFailed, report.Errors[1].Code)
+		assert.Equal(t, "first_name", report.Errors[1].Details[0].Field)
+		assert.Equal(t, models.ImportError{Record: 4, Code: models.ProblemPersonExists, Message: "Person 81eb745b-3aae-400b-959f-748fcafafd81 already exists"}, report.Errors[2])
+	})
+	t.Run("Upsert", func(t *testing.T) {
+		w, report := request("/people:import?upsert=true", "application/x-ndjson",
+			`{"id":"81eb745b-3aae-400b-959f-748fcafafd81","first_name":"John","last_name":"Roe","phone_number":"+1 (800) 555-1212"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, report.Imported)
+		person, err := store.FindByID(uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81")))
+		assert.Nil(t, err)
+		assert.Equal(t, "Roe", person.LastName)
+	})
+	t.Run("Incomplete", func(t *testing.T) {
+		w, report := request("/people:import", "text/csv", "first_name,last_name,phone_number\n\"Fay,Lee,+1 (800) 555-2006\n")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, report.Complete)
+		assert.Equal(t, 1, report.Failed)
+	})
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		w, _ := request("/people:import", "application/json", `{"first_name":"Gus"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), string(models.ProblemUnsupportedMediaType))
+	})
+	t.Run("Invalid Upsert", func(t *testing.T) {
+		w, _ := request("/people:import?upsert=maybe", "application/x-ndjson", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), string(models.ProblemInvalidParameter))
+	})
+	t.Run("Method Not Allowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people:import", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), string(models.ProblemMethodNotAllowed))
+	})
+	t.Run("Unknown Custom Method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/people:purge", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), string(models.ProblemNotFound))
+	})
+}
+
+func TestAPI_ExportPeople(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead}},
+		{Name: "pii", Key: "pii-key", Scopes: []string{ScopeRead, ScopePII}},
+	}})
+	assert.Nil(t, err)
+	router := New(models.NewMemoryStore(models.SamplePeople()...),
+		WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuthenticator(authenticator)).Router()
+
+	request := func(accept, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/people:export", nil)
+		r.Header.Set("Accept", accept)
+		r.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		w := request("", "pii-key")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="people.ndjson"`, w.Header().Get("Content-Disposition"))
+		var exported []models.Person
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var person models.Person
+			assert.Nil(t, json.Unmarshal(scanner.Bytes(), &person))
+			exported = append(exported, person)
+		}
+		assert.Len(t, exported, len(models.SamplePeople()))
+		assert.Equal(t, *models.SamplePeople()[0], exported[0])
+	})
+	t.Run("CSV", func(t *testing.T) {
+		w := request("text/csv", "pii-key")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="people.csv"`, w.Header().Get("Content-Disposition"))
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, rows, len(models.SamplePeople())+1)
+		assert.Equal(t, []string{"id", "first_name", "last_name", "phone_number"}, rows[0])
+	})
+	t.Run("Redacted", func(t *testing.T) {
+		w := request("application/jsonl", "reader-key")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		sample := models.SamplePeople()[0]
+		assert.Contains(t, w.Body.String(), models.MaskPhoneNumber(sample.PhoneNumber))
+		assert.NotContains(t, w.Body.String(), sample.PhoneNumber)
+	})
+	t.Run("Not Acceptable", func(t *testing.T) {
+		w := request("application/xml", "reader-key")
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := request("", "")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// blockingWriter is a response whose writes wait until release is closed, like a client that has stopped reading
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestAPI_ExportPeoplePages(t *testing.T) {
+	people := make([]*models.Person, 0, 2*exportPageSize+50)
+	for i := 0; i < cap(people); i++ {
+		people = append(people, &models.Person{ID: uuid.NewV4(), FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"})
+	}
+	store := models.NewMemoryStore(people...)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	assert.Nil(t, err)
+	router := New(store, WithAccessLog(AccessLogConfig{Output: ioutil.Discard}), WithAuditLog(auditLog)).Router()
+
+	t.Run("Every Page Audited", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people:export", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, strings.Split(strings.TrimSpace(w.Body.String()), "\n"), len(people))
+		result, err := audit.VerifyFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), result.Entries)
+	})
+	t.Run("Stalled Client Doesn't Block Changes", func(t *testing.T) {
+		w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+		exported := make(chan struct{})
+		go func() {
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people:export", nil))
+			close(exported)
+		}()
+		<-w.writing
+
+		updated := make(chan error, 1)
+		go func() { updated <- store.Update(people[0]) }()
+		select {
+		case err := <-updated:
+			assert.Nil(t, err)
+		case <-time.After(2 * time.Second):
+			t.Error("update blocked by the export")
+		}
+		close(w.release)
+		<-exported
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheControl lets caches store people but makes them revalidate with the ETag before every use
+const DefaultCacheControl = "no-cache"
+
+// WithCacheControl overrides the Cache-Control policy of individual people, DefaultCacheControl if empty. Responses
+// are always marked private when authentication is enabled.
+func WithCacheControl(policy string) Option {
+	return func(api *API) {
+		api.cacheControl = policy
+	}
+}
+
+// etag returns the strong ETag of a person's revision. Masked and unmasked responses are different representations so
+// they have different tags.
+func etag(revision models.Revision, redacted bool) string {
+	if redacted {
+		return fmt.Sprintf(`"v%d-masked"`, revision.Version)
+	}
+	return fmt.Sprintf(`"v%d"`, revision.Version)
+}
+
+// etagVersion returns the version an ETag was generated from, false for weak or unrecognised tags
+func etagVersion(tag string) (uint64, bool) {
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) || len(tag) < 4 {
+		return 0, false
+	}
+	value := strings.TrimSuffix(tag[2:len(tag)-1], "-masked")
+	version, err := strconv.ParseUint(value, 10, 64)
+	return version, err == nil
+}
+
+// etagList splits an If-Match or If-None-Match header into its tags
+func etagList(header string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// writeRevisionHeaders sets the ETag and Last-Modified of the person's revision
+func (api *API) writeRevisionHeaders(w http.ResponseWriter, r *http.Request, revision models.Revision) {
+	w.Header().Set("ETag", etag(revision, !api.hasScope(r, ScopePII)))
+	if !revision.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", revision.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// writeCacheHeaders sets the Cache-Control policy, and the request headers the response varies by
+func (api *API) writeCacheHeaders(w http.ResponseWriter) {
+	policy := api.cacheControl
+	if len(policy) == 0 {
+		policy = DefaultCacheControl
+	}
+	if api.authenticator != nil {
+		policy = "private, " + policy
+		w.Header().Add("Vary", "Authorization, "+auth.APIKeyHeader)
+	}
+	w.Header().Set("Cache-Control", policy)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it, reporting whether the client's copy of the
+// representation is current. Tags are compared weakly as RFC 7232 requires.
+func notModified(r *http.Request, tag string, revision models.Revision) bool {
+	if header := r.Header.Get("If-None-Match"); len(header) > 0 {
+		for _, candidate := range etagList(header) {
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !revision.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// preconditionFailed evaluates If-Match, or If-Unmodified-Since without it, reporting whether the request was made
+// against a different revision than the current one. Either representation's tag matches, weak tags never do.
+func preconditionFailed(r *http.Request, revision models.Revision) bool {
+	if header := r.Header.Get("If-Match"); len(header) > 0 {
+		for _, candidate := range etagList(header) {
+			if version, ok := etagVersion(candidate); candidate == "*" || ok && version == revision.Version {
+				return false
+			}
+		}
+		return true
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	return err == nil && revision.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+func (api *API) writePreconditionFailed(w http.ResponseWriter, r *http.Request) {
+	api.writeErrorResponse(w, r, models.ProblemPreconditionFailed, "The person has been modified since the provided revision", http.StatusPreconditionFailed)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_ConditionalRequests(t *testing.T) {
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}))
+	router := api.Router()
+	path := "/people/81eb745b-3aae-400b-959f-748fcafafd81"
+
+	request := func(method, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	first := request(http.MethodGet, "", nil)
+	lastModified := first.Header().Get("Last-Modified")
+
+	t.Run("Revision Headers", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, `"v1"`, first.Header().Get("ETag"))
+		assert.Equal(t, DefaultCacheControl, first.Header().Get("Cache-Control"))
+		_, err := http.ParseTime(lastModified)
+		assert.Nil(t, err)
+	})
+	t.Run("If-None-Match", func(t *testing.T) {
+		for _, header := range []string{`"v1"`, `W/"v1"`, `"v0", "v1"`, "*"} {
+			w := request(http.MethodGet, "", map[string]string{"If-None-Match": header})
+
+			assert.Equal(t, http.StatusNotModified, w.Code, header)
+			assert.Empty(t, w.Body.String())
+			assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+		}
+		w := request(http.MethodGet, "", map[string]string{"If-None-Match": `"v2"`, "If-Modified-Since": lastModified})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		w := request(http.MethodGet, "", map[string]string{"If-Modified-Since": lastModified})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+
+		w = request(http.MethodGet, "", map[string]string{"If-Modified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("If-Match", func(t *testing.T) {
+		w := request(http.MethodPatch, `{"last_name":"Roe"}`, map[string]string{"If-Match": `"v1"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+
+		w = request(http.MethodPut, `{"first_name":"John","last_name":"Poe","phone_number":"+1 (800) 555-1212"}`, map[string]string{"If-Match": `"v1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+
+		w = request(http.MethodPatch, `{"last_name":"Poe"}`, map[string]string{"If-Match": `W/"v2"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = request(http.MethodDelete, "", map[string]string{"If-Match": `"v1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		person, err := api.store.FindByID(models.AllPeople()[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "Roe", person.LastName)
+	})
+	t.Run("If-Unmodified-Since", func(t *testing.T) {
+		w := request(http.MethodPatch, `{"last_name":"Poe"}`, map[string]string{"If-Unmodified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+	t.Run("Conditional Delete", func(t *testing.T) {
+		w := request(http.MethodDelete, "", map[string]string{"If-Match": `"v2"`})
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
+
+func TestAPI_ConditionalRequests_Authenticated(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead, ScopeWrite}},
+	}})
+	assert.Nil(t, err)
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuthenticator(authenticator), WithCacheControl("max-age=60"))
+	router := api.Router()
+
+	request := func(method, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/people/81eb745b-3aae-400b-959f-748fcafafd81", strings.NewReader(`{"last_name":"Roe"}`))
+		r.Header.Set(auth.APIKeyHeader, "reader-key")
+		if method == http.MethodGet {
+			r.Header.Set("If-None-Match", etag)
+		} else {
+			r.Header.Set("If-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodGet, `"v1"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v1-masked"`, w.Header().Get("ETag"))
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"Accept", "Authorization, X-API-Key"}, w.Header().Values("Vary"))
+
+	assert.Equal(t, http.StatusNotModified, request(http.MethodGet, `"v1-masked"`).Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodPatch, `"v1-masked"`).Code)
+}
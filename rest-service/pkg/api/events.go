@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// LastEventIDHeader is sent by Server-Sent Events clients when reconnecting, with the ID of the last event received
+const LastEventIDHeader = "Last-Event-ID"
+
+// eventKeepAlive is how often a comment is sent on an idle event stream so proxies don't close it
+var eventKeepAlive = 15 * time.Second
+
+// eventWriteTimeout bounds each write to an event stream in place of the server's write timeout, which would otherwise
+// end every stream once it had been open that long
+var eventWriteTimeout = 30 * time.Second
+
+// WithEvents serves the changes published to bus as Server-Sent Events at /people/events
+func WithEvents(bus *models.EventBus) Option {
+	return func(api *API) {
+		api.events = bus
+	}
+}
+
+// CloseStreams ends every open event stream, and any opened afterwards, so shutting down doesn't wait for clients that
+// never disconnect. EventSource clients reconnect by themselves, to another instance once this one stops listening.
+func (api *API) CloseStreams() {
+	api.closeStreams.Do(func() { close(api.streamsClosed) })
+}
+
+// StreamEvents sends every change to people as Server-Sent Events. Clients resume after the event in the
+// Last-Event-ID header or last_event_id parameter, otherwise only changes made after connecting are sent. Event IDs
+// include the bus's epoch, so a Last-Event-ID from before the service restarted is answered with a 410 rather than
+// resuming from an unrelated event.
+//
+// Streams stay open for as long as the client keeps reading, each write only has to finish within eventWriteTimeout.
+// Clients that fall too far behind are disconnected, EventSource clients reconnect with the Last-Event-ID
+// automatically.
+func (api *API) StreamEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	lastEventID := r.Header.Get(LastEventIDHeader)
+	if len(lastEventID) == 0 {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	after := api.events.LastID()
+	if len(lastEventID) > 0 {
+		var err error
+		after, err = api.events.ParseEventID(lastEventID)
+		if errors.Is(err, models.ErrEventExpired) {
+			api.writeErrorResponse(w, r, models.ProblemEventExpired, fmt.Sprintf("Event %s is from before the service restarted, fetch the people again and reconnect without a last event ID", lastEventID), http.StatusGone)
+			return
+		} else if err != nil {
+			api.writeErrorResponse(w, r, models.ProblemInvalidParameter, fmt.Sprintf("Invalid last event ID provided, %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Error streaming events, the response writer can't be flushed")
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to stream events.", http.StatusInternalServerError)
+		return
+	}
+
+	subscription, err := api.events.Subscribe(after)
+	if errors.Is(err, models.ErrEventExpired) {
+		api.writeErrorResponse(w, r, models.ProblemEventExpired, fmt.Sprintf("Event %s is no longer available, fetch the people again and reconnect without a last event ID", lastEventID), http.StatusGone)
+		return
+	} else if err != nil {
+		log.Printf("Error subscribing to events, %s\n", err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to stream events.", http.StatusInternalServerError)
+		return
+	}
+	defer subscription.Close()
+
+	if err := extendWriteDeadline(w, eventWriteTimeout); err != nil {
+		log.Printf("Error extending the event stream's write deadline, it ends at the server's write timeout, %s\n", err.Error())
+	}
+	w.Header().Set("Content-Type", encoding.MediaTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stops nginx buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-api.streamsClosed:
+			return
+		case <-keepAlive.C:
+			_ = extendWriteDeadline(w, eventWriteTimeout)
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Fell too far behind, the client reconnects and resumes from the last event it received
+				return
+			}
+			_ = extendWriteDeadline(w, eventWriteTimeout)
+			if err := api.writeEvent(w, r, event); err != nil {
+				log.Printf("Error writing event %d, %s\n", event.ID, err.Error())
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event as a Server-Sent Event, redacting the person
+func (api *API) writeEvent(w io.Writer, r *http.Request, event models.Event) error {
+	if event.Person != nil {
+		// Nothing is sent that couldn't be audited
+		if err := api.audit(r, audit.ActionRead, []uuid.UUID{event.PersonID}, nil, nil); err != nil {
+			return err
+		}
+		// The bus shares the event with every subscriber so it is redacted in a copy
+		person := *event.Person
+		api.redact(r, &person)
+		event.Person = &person
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", api.events.EventID(event), event.Type, data)
+	return err
+}
+
+// extendWriteDeadline lets the response be written to for timeout from now, replacing the server's write timeout
+func extendWriteDeadline(w http.ResponseWriter, timeout time.Duration) error {
+	return http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is a Server-Sent Event read by readEvent
+type sseEvent struct {
+	id, event string
+	data      models.Event
+}
+
+// readEvent reads the next event of a stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.Nil(t, err) {
+			return event
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case len(line) == 0 && len(event.id) > 0:
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data))
+		}
+	}
+}
+
+func TestAPI_StreamEvents(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeRead}},
+		{Name: "writer", Key: "writer-key", Scopes: []string{ScopeWrite}},
+	}})
+	assert.Nil(t, err)
+	bus := models.NewEventBus(2)
+	store := models.NewPublishingStore(models.NewMemoryStore(models.SamplePeople()...), bus)
+	server := httptest.NewServer(New(store, WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuthenticator(authenticator), WithEvents(bus)).Router())
+	defer server.Close()
+
+	eventID := func(id uint64) string {
+		return bus.EventID(models.Event{ID: id})
+	}
+
+	// connect opens an event stream, which is closed when the test ends
+	connect := func(t *testing.T, headers map[string]string) (*http.Response, *bufio.Reader) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/people/events", nil)
+		assert.Nil(t, err)
+		r.Header.Set("Accept", "text/event-stream")
+		r.Header.Set(auth.APIKeyHeader, "reader-key")
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp, bufio.NewReader(resp.Body)
+	}
+	change := func(method, path, body string) {
+		r, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		r.Header.Set(auth.APIKeyHeader, "writer-key")
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		_ = resp.Body.Close()
+		assert.Less(t, resp.StatusCode, 300)
+	}
+
+	t.Run("Streams Changes", func(t *testing.T) {
+		resp, reader := connect(t, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		change(http.MethodPatch, "/people/81eb745b-3aae-400b-959f-748fcafafd81", `{"last_name":"Roe"}`)
+		change(http.MethodDelete, "/people/5b81b629-9026-450d-8e46-da4f8c7bd513", "")
+
+		updated := readEvent(t, reader)
+		assert.Equal(t, eventID(1), updated.id)
+		assert.Equal(t, string(models.EventPersonUpdated), updated.event)
+		assert.Equal(t, uint64(1), updated.data.ID)
+		assert.Equal(t, "Roe", updated.data.Person.LastName)
+		// The reader doesn't have the PII scope
+		assert.Equal(t, models.MaskPhoneNumber("+1 (800) 555-1212"), updated.data.Person.PhoneNumber)
+
+		deleted := readEvent(t, reader)
+		assert.Equal(t, eventID(2), deleted.id)
+		assert.Equal(t, string(models.EventPersonDeleted), deleted.event)
+		assert.Equal(t, "5b81b629-9026-450d-8e46-da4f8c7bd513", deleted.data.PersonID.String())
+		assert.Nil(t, deleted.data.Person)
+	})
+	t.Run("Resume From Last Event ID", func(t *testing.T) {
+		_, reader := connect(t, map[string]string{LastEventIDHeader: eventID(1)})
+		assert.Equal(t, eventID(2), readEvent(t, reader).id)
+
+		_, reader = connect(t, nil)
+		change(http.MethodPost, "/people", `{"first_name":"Jack","last_name":"Doe","phone_number":"+1 (800) 555-1515"}`)
+		created := readEvent(t, reader)
+		assert.Equal(t, eventID(3), created.id)
+		assert.Equal(t, "Jack", created.data.Person.FirstName)
+	})
+	t.Run("Resume From Query Parameter", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/people/events?last_event_id="+url.QueryEscape(eventID(2)), nil)
+		r.Header.Set(auth.APIKeyHeader, "reader-key")
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		assert.Equal(t, eventID(3), readEvent(t, bufio.NewReader(resp.Body)).id)
+	})
+	t.Run("Expired Last Event ID", func(t *testing.T) {
+		resp, _ := connect(t, map[string]string{LastEventIDHeader: eventID(0)})
+
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		var problem models.Error
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, models.ProblemEventExpired, problem.Code)
+	})
+	t.Run("Last Event ID Before Restart", func(t *testing.T) {
+		// The same event ID from an earlier run of the service
+		before := models.NewEventBus(models.DefaultEventHistory).EventID(models.Event{ID: 1})
+		resp, _ := connect(t, map[string]string{LastEventIDHeader: before})
+
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+		var problem models.Error
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, models.ProblemEventExpired, problem.Code)
+	})
+	t.Run("Invalid Last Event ID", func(t *testing.T) {
+		for _, lastEventID := range []string{"latest", "1", bus.Epoch().String() + ":latest"} {
+			resp, _ := connect(t, map[string]string{LastEventIDHeader: lastEventID})
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, lastEventID)
+		}
+	})
+	t.Run("Not Acceptable", func(t *testing.T) {
+		resp, _ := connect(t, map[string]string{"Accept": "application/json"})
+
+		assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+	})
+	t.Run("Keep Alive", func(t *testing.T) {
+		defer func(interval time.Duration) { eventKeepAlive = interval }(eventKeepAlive)
+		eventKeepAlive = 10 * time.Millisecond
+
+		_, reader := connect(t, nil)
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, ": keep-alive\n", line)
+	})
+}
+
+func TestAPI_StreamEventsWriteTimeout(t *testing.T) {
+	bus := models.NewEventBus(models.DefaultEventHistory)
+	server := httptest.NewUnstartedServer(New(models.NewMemoryStore(), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithEvents(bus)).Router())
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/people/events")
+	assert.Nil(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The stream is still open after the server's write timeout has passed
+	time.Sleep(200 * time.Millisecond)
+	person := models.SamplePeople()[0]
+	bus.Publish(models.EventPersonUpdated, person.ID, person)
+	assert.Equal(t, bus.EventID(models.Event{ID: 1}), readEvent(t, bufio.NewReader(resp.Body)).id)
+}
+
+func TestAPI_StreamEventsShutdown(t *testing.T) {
+	bus := models.NewEventBus(models.DefaultEventHistory)
+	api := New(models.NewMemoryStore(), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}), WithEvents(bus))
+	config := server.DefaultConfig()
+	config.Addr = "127.0.0.1:0"
+	config.ShutdownTimeout = 2 * time.Second
+	srv := server.New(api.Router(), config)
+	srv.RegisterOnShutdown(api.CloseStreams)
+	assert.Nil(t, srv.Start())
+
+	resp, err := http.Get("http://" + srv.Addr() + "/people/events")
+	assert.Nil(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The open stream is ended rather than waited for until the shutdown timeout
+	start := time.Now()
+	assert.Nil(t, srv.Shutdown(context.Background()))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	_, err = ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/health"
+	"net/http"
+)
+
+// Health returns the checker behind /healthz and /readyz so more checks can be registered
+func (api *API) Health() *health.Checker {
+	return api.health
+}
+
+// Liveness reports whether the service is alive
+func (api *API) Liveness(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	api.health.LivenessHandler().ServeHTTP(w, r)
+}
+
+// Readiness reports whether the service should receive traffic, with the result of every readiness check
+func (api *API) Readiness(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	api.health.ReadinessHandler().ServeHTTP(w, r)
+}
+
+// checkStore verifies the store can be read, stores load their data before being handed to the API so this also
+// confirms the data is loaded
+func (api *API) checkStore(context.Context) error {
+	if _, err := api.store.Count(); err != nil {
+		return fmt.Errorf("store unreachable, %w", err)
+	}
+	return nil
+}
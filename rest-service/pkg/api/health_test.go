@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/health"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPI_Health(t *testing.T) {
+	api := New(models.NewMemoryStore(models.SamplePeople()...))
+	router := api.Router()
+
+	probe := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		_ = json.NewDecoder(w.Result().Body).Decode(&report)
+		_ = w.Result().Body.Close()
+		return w.Code, report
+	}
+
+	t.Run("Live", func(t *testing.T) {
+		code, report := probe("/healthz")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusOK, report.Status)
+	})
+	t.Run("Ready", func(t *testing.T) {
+		code, report := probe("/readyz")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []health.CheckResult{{Name: "store", Status: health.StatusOK, DurationMS: report.Checks[0].DurationMS}}, report.Checks)
+	})
+	t.Run("Not Ready", func(t *testing.T) {
+		api.Health().AddReadinessCheck("dependency", func(context.Context) error { return errors.New("unavailable") })
+		code, report := probe("/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusFail, report.Status)
+
+		code, _ = probe("/healthz")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
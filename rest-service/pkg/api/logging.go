@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader is read for a caller provided request ID and always set on the response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID propagated from a caller, longer IDs are replaced
+const maxRequestIDLength = 128
+
+// LogFormat is the encoding of access log entries
+type LogFormat string
+
+const (
+	LogFormatJSON   LogFormat = "json"
+	LogFormatLogfmt LogFormat = "logfmt"
+)
+
+// LogLevel is the severity of an access log entry, requests are logged at LevelInfo unless they fail
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var logLevelNames = map[LogLevel]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+func (l LogLevel) String() string {
+	return logLevelNames[l]
+}
+
+// ParseLogLevel converts a level name such as "warn" to a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// ParseLogFormat validates a log format name
+func ParseLogFormat(name string) (LogFormat, error) {
+	switch format := LogFormat(strings.ToLower(name)); format {
+	case LogFormatJSON, LogFormatLogfmt:
+		return format, nil
+	}
+	return LogFormatLogfmt, fmt.Errorf("unknown log format %q, must be json or logfmt", name)
+}
+
+// AccessLogConfig controls where and how RequestLogger writes access log entries
+type AccessLogConfig struct {
+	Output io.Writer
+	Format LogFormat
+	// Level is the minimum level written, LevelWarn only logs failed requests
+	Level LogLevel
+}
+
+// DefaultAccessLogConfig logs every request to standard error as logfmt
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{Output: os.Stderr, Format: LogFormatLogfmt, Level: LevelInfo}
+}
+
+// accessLogger serializes writing access log entries
+type accessLogger struct {
+	mu     sync.Mutex
+	config AccessLogConfig
+}
+
+// accessLogEntry is a single request in the access log, fields are written in this order
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	Level      string  `json:"level"`
+	RequestID  string  `json:"request_id"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	URL        string  `json:"url"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	UserAgent  string  `json:"user_agent"`
+}
+
+func (l *accessLogger) write(level LogLevel, entry accessLogEntry) {
+	if level < l.config.Level {
+		return
+	}
+	entry.Level = level.String()
+
+	var line []byte
+	if l.config.Format == LogFormatJSON {
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("Error encoding access log entry, %s\n", err.Error())
+			return
+		}
+		line = append(encoded, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("time=%s level=%s request_id=%s remote_addr=%s method=%s url=%s status=%d bytes=%d duration_ms=%s user_agent=%s\n",
+			entry.Time, entry.Level, logfmtValue(entry.RequestID), logfmtValue(entry.RemoteAddr), logfmtValue(entry.Method),
+			logfmtValue(entry.URL), entry.Status, entry.Bytes, strconv.FormatFloat(entry.DurationMS, 'f', -1, 64),
+			logfmtValue(entry.UserAgent)))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.config.Output.Write(line); err != nil {
+		log.Printf("Error writting access log entry, %s\n", err.Error())
+	}
+}
+
+// logfmtValue quotes values that contain spaces, quotes or equals signs
+func logfmtValue(value string) string {
+	if len(value) == 0 || strings.ContainsAny(value, " \"=\t\n\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// requestIDContextKey is the context key of the request ID
+type requestIDContextKey struct{}
+
+// RequestIDFromContext returns the ID RequestLogger assigned to the request, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// requestID returns the caller's request ID if it is usable, otherwise generates a new one
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return uuid.NewV4().String()
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return uuid.NewV4().String()
+		}
+	}
+	return id
+}
+
+// responseRecorder captures the status code and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection through the recorder
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// RequestLogger writes a structured access log entry after the handler has responded, with the status, size and
+// duration of the response. Every request is given an X-Request-ID, propagated from the caller if provided, that is
+// returned in the response and available to handlers through RequestIDFromContext.
+func (api *API) RequestLogger(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		id := requestID(r)
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id))
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler(recorder, r, ps)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		level := LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = LevelError
+		} else if recorder.status >= http.StatusBadRequest {
+			level = LevelWarn
+		}
+		api.accessLog.write(level, accessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			RequestID:  id,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			URL:        r.URL.String(),
+			Status:     recorder.status,
+			Bytes:      recorder.bytes,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			UserAgent:  r.UserAgent(),
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_RequestLogger(t *testing.T) {
+	var output bytes.Buffer
+	api := New(models.NewMemoryStore(), WithAccessLog(AccessLogConfig{Output: &output, Format: LogFormatJSON, Level: LevelInfo}))
+	var handlerRequestID string
+	handler := api.RequestLogger(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handlerRequestID = RequestIDFromContext(r.Context())
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		output.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/people?last_name=Smith", nil)
+		r.Header.Set("User-Agent", "test agent")
+		w := httptest.NewRecorder()
+
+		handler(w, r, nil)
+		var entry map[string]interface{}
+		err := json.Unmarshal(output.Bytes(), &entry)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, entry["time"])
+		assert.Equal(t, "info", entry["level"])
+		assert.Equal(t, w.Result().Header.Get(RequestIDHeader), entry["request_id"])
+		assert.Equal(t, handlerRequestID, entry["request_id"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/people?last_name=Smith", entry["url"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.Equal(t, float64(5), entry["bytes"])
+		assert.Contains(t, entry, "duration_ms")
+		assert.Equal(t, "test agent", entry["user_agent"])
+	})
+	t.Run("Propagated Request ID", func(t *testing.T) {
+		output.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		r.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+
+		handler(w, r, nil)
+		var entry map[string]interface{}
+		err := json.Unmarshal(output.Bytes(), &entry)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "abc-123", w.Result().Header.Get(RequestIDHeader))
+		assert.Equal(t, "abc-123", handlerRequestID)
+		assert.Equal(t, "warn", entry["level"])
+		assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+	})
+	t.Run("Invalid Request ID Replaced", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, "has spaces")
+		w := httptest.NewRecorder()
+
+		handler(w, r, nil)
+
+		assert.NotEqual(t, "has spaces", handlerRequestID)
+		assert.Len(t, handlerRequestID, 36)
+	})
+	t.Run("Logfmt", func(t *testing.T) {
+		var logfmt bytes.Buffer
+		handler := New(models.NewMemoryStore(), WithAccessLog(AccessLogConfig{Output: &logfmt, Format: LogFormatLogfmt})).
+			RequestLogger(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+		r := httptest.NewRequest(http.MethodDelete, "/people/1", nil)
+		r.Header.Set("User-Agent", "curl/7.0 (x86)")
+
+		handler(httptest.NewRecorder(), r, nil)
+		line := logfmt.String()
+
+		assert.True(t, strings.HasSuffix(line, "\n"))
+		assert.Contains(t, line, " level=info ")
+		assert.Contains(t, line, " method=DELETE url=/people/1 status=200 bytes=0 ")
+		assert.Contains(t, line, ` user_agent="curl/7.0 (x86)"`)
+	})
+	t.Run("Level Filtered", func(t *testing.T) {
+		var filtered bytes.Buffer
+		handler := New(models.NewMemoryStore(), WithAccessLog(AccessLogConfig{Output: &filtered, Format: LogFormatJSON, Level: LevelWarn})).
+			RequestLogger(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				if r.URL.Path == "/fail" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			})
+
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil)
+		assert.Equal(t, 0, filtered.Len())
+
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil), nil)
+		assert.Contains(t, filtered.String(), `"level":"error"`)
+	})
+}
+
+func TestParseLogSettings(t *testing.T) {
+	level, err := ParseLogLevel("WARN")
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, level)
+	_, err = ParseLogLevel("verbose")
+	assert.NotNil(t, err)
+
+	format, err := ParseLogFormat("json")
+	assert.Nil(t, err)
+	assert.Equal(t, LogFormatJSON, format)
+	_, err = ParseLogFormat("xml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/metrics"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiMetrics are the metrics recorded by the Metrics middleware
+type apiMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	inFlight *metrics.Gauge
+	// auditFailures counts the requests that couldn't be recorded in the audit log
+	auditFailures *metrics.CounterVec
+}
+
+func (api *API) newMetrics() *apiMetrics {
+	registry := metrics.NewRegistry()
+	m := &apiMetrics{
+		registry:      registry,
+		requests:      registry.NewCounterVec("http_requests_total", "Total HTTP requests by route, method and status code.", "route", "method", "status"),
+		duration:      registry.NewHistogramVec("http_request_duration_seconds", "HTTP request latency in seconds by route, method and status code.", metrics.DefaultBuckets, "route", "method", "status"),
+		inFlight:      registry.NewGauge("http_requests_in_flight", "HTTP requests currently being served."),
+		auditFailures: registry.NewCounterVec("audit_failures_total", "Requests that couldn't be recorded in the audit log by action.", "action"),
+	}
+	registry.NewGaugeFunc("people_store_size", "Number of people in the store.", func() float64 {
+		count, err := api.store.Count()
+		if err != nil {
+			log.Printf("Error counting people for metrics, %s\n", err.Error())
+			return -1
+		}
+		return float64(count)
+	})
+	return m
+}
+
+// Metrics records the request count, latency and in-flight requests of the route. route should be the registered
+// path pattern rather than the request path so that IDs don't create a series per person.
+func (api *API) Metrics(route string, handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		api.metrics.inFlight.Inc()
+		defer api.metrics.inFlight.Dec()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler(recorder, r, ps)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		status := strconv.Itoa(recorder.status)
+		api.metrics.requests.Inc(route, r.Method, status)
+		api.metrics.duration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	}
+}
+
+// ServeMetrics exposes the API metrics in the Prometheus text exposition format
+func (api *API) ServeMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	api.metrics.registry.Handler().ServeHTTP(w, r)
+}
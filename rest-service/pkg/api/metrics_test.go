@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPI_Metrics(t *testing.T) {
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}))
+	router := api.Router()
+
+	for _, path := range []string{
+		"/people",
+		"/people/df12ce76-767b-4bf0-bccb-816745df9e70",
+		"/people/000ebe58-b659-422b-ab48-a0d0d40bd8f9",
+		"/people/df12ce76-767b-4bf0-bccb-816745df9e71",
+		"/people/search?q=smith",
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, body, `http_requests_total{route="/people",method="GET",status="200"} 1`)
+	assert.Contains(t, body, `http_requests_total{route="/people/:id",method="GET",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{route="/people/:id",method="GET",status="404"} 1`)
+	assert.Contains(t, body, `http_requests_total{route="/people/search",method="GET",status="200"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{route="/people/:id",method="GET",status="200"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{route="/people",method="GET",status="200",le="+Inf"} 1`)
+	assert.Contains(t, body, "http_requests_in_flight 0\n")
+	assert.Contains(t, body, "people_store_size 5\n")
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// WithEncodings replaces the formats responses can be negotiated in, encoding.DefaultRegistry by default
+func WithEncodings(registry *encoding.Registry) Option {
+	return func(api *API) {
+		api.encodings = registry
+	}
+}
+
+// formatContextKey is the context key of the negotiated response format
+type formatContextKey struct{}
+
+// Negotiate picks the response format from the Accept header before handler runs, so a request that would change
+// data isn't made when its response can't be sent. Unsupported Accept headers get a 406 in the default format.
+func (api *API) Negotiate(handler httprouter.Handle) httprouter.Handle {
+	return api.negotiate(api.encodings, handler)
+}
+
+// negotiate is Negotiate choosing between formats rather than every format of the API
+func (api *API) negotiate(formats *encoding.Registry, handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Header().Add("Vary", "Accept")
+		format, ok := formats.Negotiate(r.Header.Get("Accept"))
+		if !ok {
+			r = r.WithContext(context.WithValue(r.Context(), formatContextKey{}, formats.Default()))
+			api.writeErrorResponse(w, r, models.ProblemNotAcceptable, fmt.Sprintf("None of the accepted media types are supported, must accept one of %s",
+				strings.Join(formats.MediaTypes(), ", ")), http.StatusNotAcceptable)
+			return
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), formatContextKey{}, format)), ps)
+	}
+}
+
+// responseFormat returns the format negotiated for the request, negotiating it now if Negotiate wasn't used and
+// falling back to the default format
+func (api *API) responseFormat(r *http.Request) encoding.Format {
+	if format, ok := r.Context().Value(formatContextKey{}).(encoding.Format); ok {
+		return format
+	}
+	if format, ok := api.encodings.Negotiate(r.Header.Get("Accept")); ok {
+		return format
+	}
+	return api.encodings.Default()
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_Negotiate(t *testing.T) {
+	api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}))
+	router := api.Router()
+
+	request := func(method, path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if len(accept) > 0 {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("JSON By Default", func(t *testing.T) {
+		w := request(http.MethodGet, "/people?last_name=Smith", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	})
+	t.Run("CSV", func(t *testing.T) {
+		w := request(http.MethodGet, "/people?last_name=Smith", "text/csv")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "id,first_name,last_name,phone_number\n"+
+			"df12ce76-767b-4bf0-bccb-816745df9e70,Brian,Smith,+44 7700 900077\n"+
+			"000ebe58-b659-422b-ab48-a0d0d40bd8f9,Jenny,Smith,+44 7700 900077\n", w.Body.String())
+	})
+	t.Run("XML", func(t *testing.T) {
+		w := request(http.MethodGet, "/people/81eb745b-3aae-400b-959f-748fcafafd81", "application/xml")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "<person><id>81eb745b-3aae-400b-959f-748fcafafd81</id><first_name>John</first_name>")
+	})
+	t.Run("MessagePack", func(t *testing.T) {
+		w := request(http.MethodGet, "/people/search?q=jane", "application/msgpack")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+		assert.Equal(t, byte(0x91), w.Body.Bytes()[0])
+	})
+	t.Run("Errors Use Negotiated Format", func(t *testing.T) {
+		w := request(http.MethodGet, "/people/not-an-id", "application/xml")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/problem+xml; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `<problem xmlns="urn:ietf:rfc:7807"><type>/problems/invalid_id</type><title>Invalid ID</title><status>400</status><detail>Invalid ID provided</detail>`)
+	})
+	t.Run("Not Acceptable", func(t *testing.T) {
+		w := request(http.MethodDelete, "/people/81eb745b-3aae-400b-959f-748fcafafd81", "text/html")
+
+		var response models.Error
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&response))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasSuffix(response.Detail, "application/json, text/csv, application/xml, application/msgpack"))
+
+		// The request was rejected before the person was deleted
+		_, err := api.store.FindByID(models.AllPeople()[0].ID)
+		assert.Nil(t, err)
+	})
+}
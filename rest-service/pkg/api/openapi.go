@@ -0,0 +1,418 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/health"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/openapi"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// APIVersion is the version of the API described by the OpenAPI document
+const APIVersion = "1.0.0"
+
+// serveOpenAPI returns a handler serving the OpenAPI document describing routes
+func (api *API) serveOpenAPI(routes []Route) httprouter.Handle {
+	document, err := json.Marshal(api.OpenAPI(routes))
+	if err != nil {
+		// Only possible if a schema can't be encoded, which the tests would catch
+		log.Printf("Error encoding OpenAPI document, %s\n", err.Error())
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Set("Content-Type", encoding.MediaTypeJSON)
+		w.Header().Set("Content-Length", strconv.Itoa(len(document)))
+		if _, err := w.Write(document); err != nil {
+			log.Printf("Error writting OpenAPI document, %s\n", err.Error())
+		}
+	}
+}
+
+// OpenAPI builds the OpenAPI 3 document describing routes. Routes without a known operation are left out, see
+// openAPIOperations.
+func (api *API) OpenAPI(routes []Route) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "People",
+		Description: "Search and manage people. Errors are RFC 7807 problem details with a stable code.",
+		Version:     APIVersion,
+	})
+	doc.AddSchema("Person", models.Person{})
+	doc.AddSchema("PersonPatch", models.PersonPatch{})
+	doc.AddSchema("ScoredPerson", models.ScoredPerson{})
+	doc.AddSchema("Error", models.Error{})
+	doc.AddSchema("ImportReport", models.ImportReport{})
+	doc.AddSchema("Event", models.Event{})
+	doc.AddSchema("HealthReport", health.Report{})
+	api.describeSchemas(doc)
+
+	if api.authenticator != nil {
+		doc.Components.SecuritySchemes["apiKey"] = openapi.SecurityScheme{Type: "apiKey", Name: auth.APIKeyHeader, In: "header"}
+		doc.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	}
+
+	operations := api.openAPIOperations()
+	for _, route := range routes {
+		describe, ok := operations[route.Method+" "+route.Path]
+		if !ok {
+			continue
+		}
+		operation := describe()
+		api.addRouteResponses(operation, route)
+		doc.AddOperation(route.Method, route.Path, operation)
+	}
+	return doc
+}
+
+// describeSchemas adds the validation rules and descriptions that can't be generated from the model types
+func (api *API) describeSchemas(doc *openapi.Document) {
+	maxNameLength := models.MaxNameLength
+	for _, name := range []string{"Person", "PersonPatch"} {
+		schema := doc.Components.Schemas[name]
+		schema.Properties["first_name"].MaxLength = &maxNameLength
+		schema.Properties["last_name"].MaxLength = &maxNameLength
+		schema.Properties["phone_number"].Description = "Masked unless the caller has the " + ScopePII + " scope"
+	}
+	doc.Components.Schemas["Person"].Properties["id"].ReadOnly = true
+
+	event := doc.Components.Schemas["Event"]
+	for _, eventType := range models.EventTypes() {
+		event.Properties["type"].Enum = append(event.Properties["type"].Enum, string(eventType))
+	}
+	// Siblings of a reference are ignored, so deleted people being null can't be described
+	event.Properties["person"] = openapi.Ref("Person")
+
+	problem := doc.Components.Schemas["Error"]
+	problem.Description = "An RFC 7807 problem details object"
+	problem.Properties["code"].Description = "Stable, machine readable identifier of the kind of problem"
+	for _, code := range models.ErrorCodes() {
+		problem.Properties["code"].Enum = append(problem.Properties["code"].Enum, string(code))
+	}
+	problem.Properties["type"].Description = "URI reference identifying the kind of problem, one per code"
+}
+
+// openAPIOperations returns a function describing the operation of each route, keyed by method and path
+func (api *API) openAPIOperations() map[string]func() *openapi.Operation {
+	return map[string]func() *openapi.Operation{
+		"GET /people": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "searchPeople",
+				Summary:     "List the people matching every filter",
+				Description: "Filters are exact by default, a trailing * in the value matches by prefix and a ~ after the parameter name ignores case.",
+				Tags:        []string{"people"},
+				Parameters:  append(filterParameters(), pageParameters(true)...),
+				Responses: map[string]openapi.Response{
+					"200": api.listResponse("The matching people", openapi.Ref("Person")),
+					"400": problemResponse("Invalid search or paging parameters"),
+				},
+			}
+		},
+		"POST /people": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "createPerson",
+				Summary:     "Create a person, the ID is generated by the server",
+				Tags:        []string{"people"},
+				RequestBody: jsonBody(openapi.Ref("Person")),
+				Responses: map[string]openapi.Response{
+					"201": api.personResponse("The created person", "Location"),
+					"400": problemResponse("Invalid body or person"),
+				},
+			}
+		},
+		"GET /people/search": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "fuzzySearchPeople",
+				Summary:     "Rank people by how closely they match free text",
+				Description: "Tolerates typos, partial names and phone number fragments. Results are ordered by score and can't be sorted.",
+				Tags:        []string{"people"},
+				Parameters: append([]openapi.Parameter{{
+					Name: "q", In: "query", Required: true, Description: "The names or phone number to search for",
+					Schema: &openapi.Schema{Type: "string"},
+				}}, pageParameters(false)...),
+				Responses: map[string]openapi.Response{
+					"200": api.listResponse("The matching people, best match first", openapi.Ref("ScoredPerson")),
+					"400": problemResponse("Invalid search or paging parameters"),
+				},
+			}
+		},
+		"GET /people/:id": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "getPerson",
+				Summary:     "Get a person by ID",
+				Tags:        []string{"people"},
+				Parameters: append([]openapi.Parameter{idParameter()},
+					headerParameters("If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since")...),
+				Responses: map[string]openapi.Response{
+					"200": api.personResponse("The person", "Last-Modified", "Cache-Control"),
+					"304": {Description: "The person hasn't changed since the provided revision"},
+					"400": problemResponse("Invalid ID"),
+					"404": problemResponse("Person not found"),
+					"412": problemResponse("The person has been modified since the provided revision"),
+				},
+			}
+		},
+		"PUT /people/:id": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "replacePerson",
+				Summary:     "Replace every field of a person",
+				Tags:        []string{"people"},
+				Parameters:  append([]openapi.Parameter{idParameter()}, headerParameters("If-Match", "If-Unmodified-Since")...),
+				RequestBody: jsonBody(openapi.Ref("Person")),
+				Responses:   api.updateResponses("The replaced person"),
+			}
+		},
+		"PATCH /people/:id": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "updatePerson",
+				Summary:     "Update the provided fields of a person",
+				Tags:        []string{"people"},
+				Parameters:  append([]openapi.Parameter{idParameter()}, headerParameters("If-Match", "If-Unmodified-Since")...),
+				RequestBody: jsonBody(openapi.Ref("PersonPatch")),
+				Responses:   api.updateResponses("The updated person"),
+			}
+		},
+		"DELETE /people/:id": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "deletePerson",
+				Summary:     "Delete a person",
+				Tags:        []string{"people"},
+				Parameters:  append([]openapi.Parameter{idParameter()}, headerParameters("If-Match", "If-Unmodified-Since")...),
+				Responses: map[string]openapi.Response{
+					"204": {Description: "The person was deleted"},
+					"400": problemResponse("Invalid ID"),
+					"404": problemResponse("Person not found"),
+					"412": problemResponse("The person has been modified since the provided revision"),
+				},
+			}
+		},
+		"GET /people/events": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "streamPeopleEvents",
+				Summary:     "Stream changes to people as Server-Sent Events",
+				Description: "Each event's data is an Event. Reconnecting with the ID of the last event received resumes after it, otherwise only changes made after connecting are sent. Event IDs from before the service restarted are answered with a 410.",
+				Tags:        []string{"people"},
+				Parameters: []openapi.Parameter{
+					{Name: LastEventIDHeader, In: "header", Description: "Resume after this event", Schema: &openapi.Schema{Type: "integer"}},
+					{Name: "last_event_id", In: "query", Description: "Resume after this event, for clients that can't set headers", Schema: &openapi.Schema{Type: "integer"}},
+				},
+				Responses: map[string]openapi.Response{
+					"200": {Description: "The stream of events", Content: map[string]openapi.MediaType{
+						encoding.MediaTypeEventStream: {Schema: &openapi.Schema{Type: "string"}},
+					}},
+					"400": problemResponse("Invalid last event ID"),
+					"410": problemResponse("The last event ID is no longer available to resume from"),
+				},
+			}
+		},
+		"POST /people:import": func() *openapi.Operation {
+			stream := &openapi.Schema{Type: "string", Description: "One person per line, CSV starts with a header row naming the fields"}
+			return &openapi.Operation{
+				OperationID: "importPeople",
+				Summary:     "Create a person for every record of an NDJSON or CSV stream",
+				Description: "Records are validated individually, the report lists why any weren't imported.",
+				Tags:        []string{"people"},
+				Parameters: []openapi.Parameter{{
+					Name: "upsert", In: "query", Description: "Replace existing people with the same ID instead of rejecting the record",
+					Schema: &openapi.Schema{Type: "boolean"},
+				}},
+				RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+					encoding.MediaTypeNDJSON: {Schema: stream},
+					encoding.MediaTypeCSV:    {Schema: stream},
+				}},
+				Responses: map[string]openapi.Response{
+					"200": {Description: "Which records were imported", Content: api.negotiatedContent(openapi.Ref("ImportReport"))},
+					"400": problemResponse("Invalid upsert parameter"),
+					"415": problemResponse("The body isn't NDJSON or CSV"),
+				},
+			}
+		},
+		"GET /people:export": func() *openapi.Operation {
+			stream := &openapi.Schema{Type: "string", Description: "One person per line, CSV starts with a header row"}
+			return &openapi.Operation{
+				OperationID: "exportPeople",
+				Summary:     "Stream every person as NDJSON or CSV",
+				Tags:        []string{"people"},
+				Responses: map[string]openapi.Response{
+					"200": {
+						Description: "Every person",
+						Headers: map[string]openapi.Header{
+							"Content-Disposition": {Schema: &openapi.Schema{Type: "string"}},
+						},
+						Content: map[string]openapi.MediaType{
+							encoding.MediaTypeNDJSON: {Schema: stream},
+							encoding.MediaTypeCSV:    {Schema: stream},
+						},
+					},
+				},
+			}
+		},
+		"GET /metrics": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "metrics",
+				Summary:     "Request and store metrics in the Prometheus text exposition format",
+				Tags:        []string{"operations"},
+				Responses: map[string]openapi.Response{
+					"200": {Description: "The metrics", Content: map[string]openapi.MediaType{
+						"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+					}},
+				},
+			}
+		},
+		"GET /healthz": func() *openapi.Operation {
+			return probeOperation("liveness", "Whether the service is alive")
+		},
+		"GET /readyz": func() *openapi.Operation {
+			return probeOperation("readiness", "Whether the service is ready to serve requests")
+		},
+		"GET /openapi.json": func() *openapi.Operation {
+			return &openapi.Operation{
+				OperationID: "openAPI",
+				Summary:     "This OpenAPI document",
+				Tags:        []string{"operations"},
+				Responses: map[string]openapi.Response{
+					"200": {Description: "The OpenAPI document", Content: map[string]openapi.MediaType{
+						encoding.MediaTypeJSON: {Schema: &openapi.Schema{Type: "object"}},
+					}},
+				},
+			}
+		},
+	}
+}
+
+// addRouteResponses adds the security requirements and responses of the middleware that applies to the route
+func (api *API) addRouteResponses(operation *openapi.Operation, route Route) {
+	if len(route.Scope) == 0 {
+		return
+	}
+
+	operation.Responses["406"] = problemResponse("None of the accepted media types are supported")
+	operation.Responses["500"] = problemResponse("Unexpected error")
+	if api.authenticator != nil {
+		operation.Description = fmt.Sprintf("%s Requires the %s scope.", operation.Description, route.Scope)
+		operation.Security = []openapi.SecurityRequirement{{"apiKey": {}}, {"bearer": {}}}
+		operation.Responses["401"] = problemResponse("Missing or invalid credentials")
+		operation.Responses["403"] = problemResponse("Disabled credentials or missing scope")
+	}
+	if !api.limiter(route.Path).Limit().Unlimited() {
+		operation.Responses["429"] = problemResponse("Too many requests")
+	}
+}
+
+// listResponse describes a page of results in every format that can be negotiated
+func (api *API) listResponse(description string, item *openapi.Schema) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Headers: map[string]openapi.Header{
+			"X-Total-Count": {Description: "The number of results across every page", Schema: &openapi.Schema{Type: "integer"}},
+			"Link":          {Description: "RFC 8288 links to the first, previous, next and last pages", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Content: api.negotiatedContent(&openapi.Schema{Type: "array", Items: item}),
+	}
+}
+
+// personResponse describes a single person with its revision headers in every format that can be negotiated
+func (api *API) personResponse(description string, headers ...string) openapi.Response {
+	response := openapi.Response{
+		Description: description,
+		Headers: map[string]openapi.Header{
+			"ETag": {Description: "The revision of the person", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Content: api.negotiatedContent(openapi.Ref("Person")),
+	}
+	for _, header := range headers {
+		response.Headers[header] = openapi.Header{Schema: &openapi.Schema{Type: "string"}}
+	}
+	return response
+}
+
+// negotiatedContent returns the schema in every media type responses can be negotiated in
+func (api *API) negotiatedContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType)
+	for _, mediaType := range api.encodings.MediaTypes() {
+		content[mediaType] = openapi.MediaType{Schema: schema}
+	}
+	return content
+}
+
+// updateResponses describes the responses of replacing or updating a person
+func (api *API) updateResponses(description string) map[string]openapi.Response {
+	return map[string]openapi.Response{
+		"200": api.personResponse(description, "Last-Modified"),
+		"400": problemResponse("Invalid ID, body or person"),
+		"404": problemResponse("Person not found"),
+		"412": problemResponse("The person has been modified since the provided revision"),
+	}
+}
+
+func problemResponse(description string) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content: map[string]openapi.MediaType{
+			encoding.MediaTypeProblemJSON: {Schema: openapi.Ref("Error")},
+			encoding.MediaTypeProblemXML:  {Schema: openapi.Ref("Error")},
+		},
+	}
+}
+
+func probeOperation(name, summary string) *openapi.Operation {
+	report := map[string]openapi.MediaType{encoding.MediaTypeJSON: {Schema: openapi.Ref("HealthReport")}}
+	return &openapi.Operation{
+		OperationID: name,
+		Summary:     summary,
+		Tags:        []string{"operations"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Every check passed", Content: report},
+			"503": {Description: "A check failed", Content: report},
+		},
+	}
+}
+
+func jsonBody(schema *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{encoding.MediaTypeJSON: {Schema: schema}}}
+}
+
+func idParameter() openapi.Parameter {
+	return openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Format: "uuid"}}
+}
+
+// filterParameters describes the exact and case-insensitive filters of SearchPeople
+func filterParameters() []openapi.Parameter {
+	parameters := make([]openapi.Parameter, 0, 6)
+	for _, field := range []string{models.FieldFirstName, models.FieldLastName, models.FieldPhoneNumber} {
+		parameters = append(parameters,
+			openapi.Parameter{Name: field, In: "query", Description: "Exact match, or prefix match with a trailing *", Schema: &openapi.Schema{Type: "string"}},
+			openapi.Parameter{Name: field + "~", In: "query", Description: "Case-insensitive match", Schema: &openapi.Schema{Type: "string"}},
+		)
+	}
+	return parameters
+}
+
+// pageParameters describes the parameters parsed by parsePage
+func pageParameters(sortable bool) []openapi.Parameter {
+	minLimit, maxLimit, minOffset := float64(1), float64(MaxPageLimit), float64(0)
+	parameters := []openapi.Parameter{
+		{Name: "limit", In: "query", Description: fmt.Sprintf("The number of results, %d by default", DefaultPageLimit),
+			Schema: &openapi.Schema{Type: "integer", Minimum: &minLimit, Maximum: &maxLimit}},
+		{Name: "offset", In: "query", Description: "The number of results to skip", Schema: &openapi.Schema{Type: "integer", Minimum: &minOffset}},
+	}
+	if sortable {
+		parameters = append(parameters, openapi.Parameter{
+			Name: "sort", In: "query", Description: "Comma separated fields to sort by, prefixed with - to sort descending",
+			Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	return parameters
+}
+
+func headerParameters(names ...string) []openapi.Parameter {
+	parameters := make([]openapi.Parameter, 0, len(names))
+	for _, name := range names {
+		parameters = append(parameters, openapi.Parameter{Name: name, In: "header", Schema: &openapi.Schema{Type: "string"}})
+	}
+	return parameters
+}
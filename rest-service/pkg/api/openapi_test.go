@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/openapi"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_OpenAPI(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Name: "reader", Key: "secret", Scopes: []string{ScopeRead}}}})
+	assert.Nil(t, err)
+	api := New(models.NewMemoryStore(models.SamplePeople()...),
+		WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+		WithAuthenticator(authenticator),
+		WithRateLimits(RateLimitConfig{Routes: map[string]ratelimit.Limit{"/people/search": {Rate: 1, Burst: 1}}}),
+		WithEvents(models.NewEventBus(models.DefaultEventHistory)))
+	router := api.Router()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc openapi.Document
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&doc))
+
+	t.Run("Served", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, openapi.Version, doc.OpenAPI)
+	})
+	t.Run("Every Route Described", func(t *testing.T) {
+		routes := api.Routes()
+		assert.Len(t, routes, 14)
+		for _, route := range routes {
+			assert.NotNil(t, doc.Operation(route.Method, route.Path), "%s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	})
+	t.Run("Every Path Routed", func(t *testing.T) {
+		customMethods := api.routeTable().methods
+		for path, item := range doc.Paths {
+			for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+				if item.Operation(method) == nil {
+					continue
+				}
+				handler, _, _ := router.Lookup(method, strings.Replace(path, "{id}", "81eb745b-3aae-400b-959f-748fcafafd81", 1))
+				if handler == nil {
+					handler = customMethods[path][method]
+				}
+				assert.NotNil(t, handler, "%s %s is not routed", method, path)
+			}
+		}
+	})
+	t.Run("Schemas", func(t *testing.T) {
+		person := doc.Components.Schemas["Person"]
+		assert.Equal(t, []string{"id", "first_name", "last_name", "phone_number"}, person.Required)
+		assert.Equal(t, "uuid", person.Properties["id"].Format)
+		assert.Equal(t, models.MaxNameLength, *person.Properties["first_name"].MaxLength)
+
+		problem := doc.Components.Schemas["Error"]
+		assert.Contains(t, problem.Required, "code")
+		assert.Contains(t, problem.Properties["code"].Enum, string(models.ProblemPersonNotFound))
+		assert.Equal(t, "array", problem.Properties["details"].Type)
+	})
+	t.Run("Middleware Responses", func(t *testing.T) {
+		search := doc.Operation(http.MethodGet, "/people/search")
+		assert.Contains(t, search.Responses, "401")
+		assert.Contains(t, search.Responses, "429")
+		assert.Contains(t, search.Description, ScopeRead)
+		assert.Equal(t, "#/components/schemas/Error", search.Responses["400"].Content["application/problem+json"].Schema.Ref)
+		assert.NotContains(t, doc.Operation(http.MethodGet, "/people").Responses, "429")
+		assert.NotContains(t, doc.Operation(http.MethodGet, "/healthz").Responses, "401")
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPageLimit is the number of results returned when no limit is requested
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest limit a client may request
+	MaxPageLimit = 1000
+)
+
+// pageParams are the query parameters used for paging and sorting rather than searching
+var pageParams = []string{"limit", "offset", "sort"}
+
+// isPageParam reports whether the query parameter is used for paging or sorting
+func isPageParam(param string) bool {
+	for _, pageParam := range pageParams {
+		if param == pageParam {
+			return true
+		}
+	}
+	return false
+}
+
+// page describes the slice of results requested by the client
+type page struct {
+	limit  int
+	offset int
+	sort   []models.SortField
+}
+
+// parsePage reads the limit, offset and sort query parameters
+func parsePage(r *http.Request) (page, error) {
+	result := page{limit: DefaultPageLimit}
+
+	if value := r.FormValue("limit"); len(value) > 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return result, fmt.Errorf("limit must be a number between 1 and %d", MaxPageLimit)
+		}
+		result.limit = limit
+	}
+
+	if value := r.FormValue("offset"); len(value) > 0 {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return result, fmt.Errorf("offset must be a number greater than or equal to 0")
+		}
+		result.offset = offset
+	}
+
+	sortFields, err := models.ParseSort(r.FormValue("sort"))
+	if err != nil {
+		return result, err
+	}
+	result.sort = sortFields
+
+	return result, nil
+}
+
+// apply sorts the results and returns only those in the page
+func (p page) apply(results []*models.Person) []*models.Person {
+	models.SortPeople(results, p.sort)
+	return models.Paginate(results, p.limit, p.offset)
+}
+
+// bounds returns the start and end indexes of the page within total results
+func (p page) bounds(total int) (int, int) {
+	if p.offset >= total {
+		return total, total
+	}
+	if end := p.offset + p.limit; end < total {
+		return p.offset, end
+	}
+	return p.offset, total
+}
+
+// writePageHeaders Sets the X-Total-Count and RFC 8288 Link headers so clients can page through the results
+func (api *API) writePageHeaders(w http.ResponseWriter, r *http.Request, p page, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	links := make([]string, 0, 4)
+	link := func(offset int, rel string) {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(p.limit))
+		query.Set("offset", strconv.Itoa(offset))
+		target := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), rel))
+	}
+
+	lastOffset := 0
+	if total > 0 {
+		lastOffset = (total - 1) / p.limit * p.limit
+	}
+	link(0, "first")
+	if p.offset > 0 {
+		previous := p.offset - p.limit
+		if previous < 0 {
+			previous = 0
+		}
+		link(previous, "prev")
+	}
+	if p.offset+p.limit < total {
+		link(p.offset+p.limit, "next")
+	}
+	link(lastOffset, "last")
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -1,53 +1,357 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
 	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
 	"log"
 	"net/http"
+	"strings"
 )
 
+// SearchPeople lists the people matching every provided filter. Filters are exact by default, a trailing "*" in the
+// value matches by prefix and a "~" after the parameter name ignores case, e.g. `?last_name=smi*&first_name~=john`.
 func (api *API) SearchPeople(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	firstName := r.FormValue("first_name")
-	lastName := r.FormValue("last_name")
-	phoneNumber := r.FormValue("phone_number")
-
-	var results []*models.Person
-	if len(r.Form) == 0 {
-		results = models.AllPeople()
-	} else if len(firstName) > 0 && len(lastName) > 0 {
-		results = models.FindPeopleByName(firstName, lastName)
-	} else if len(phoneNumber) > 0 {
-		results = models.FindPeopleByPhoneNumber(phoneNumber)
-	} else {
-		api.writeErrorResponse(w, "Invalid search parameters provided, must provide either a first and last name or a phone number", http.StatusBadRequest)
+	p, err := parsePage(r)
+	if err != nil {
+		api.writeErrorResponse(w, r, models.ProblemInvalidPaging, fmt.Sprintf("Invalid paging parameters provided, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseQuery(r)
+	if err != nil {
+		api.writeErrorResponse(w, r, models.ProblemInvalidSearch, fmt.Sprintf("Invalid search parameters provided, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if !api.requirePII(w, r, usesPhoneNumber(query, p), "filter or sort by phone_number") {
+		return
+	}
+
+	results, err := api.store.Search(query)
+	if err != nil {
+		log.Printf("Error searching people, %s\n", err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to search people.", http.StatusInternalServerError)
 		return
 	}
 
 	/* Requirement docs do not want a 404 here, but I would normally do so.
 	if len(results) == 0 {
-		api.writeJsonResponse(w, results, http.StatusNotFound)
+		api.writeResponse(w, r, results, http.StatusNotFound)
 		return
 	}
 	*/
 
-	api.writeJsonResponse(w, results, http.StatusOK)
+	api.writePageHeaders(w, r, p, len(results))
+	results = p.apply(results)
+	if !api.recordAuditPeople(w, r, audit.ActionSearch, results) {
+		return
+	}
+	api.redact(r, results...)
+	api.writeResponse(w, r, results, http.StatusOK)
+}
+
+func (api *API) GetPerson(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := api.parseID(w, r, ps)
+	if !ok {
+		return
+	}
+
+	person, revision, ok := api.findRevision(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !api.recordAudit(w, r, audit.ActionRead, []uuid.UUID{id}, nil, nil) {
+		return
+	}
+	api.writeRevisionHeaders(w, r, revision)
+	api.writeCacheHeaders(w)
+	if notModified(r, etag(revision, !api.hasScope(r, ScopePII)), revision) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	api.redact(r, person)
+	api.writeResponse(w, r, person, http.StatusOK)
+}
+
+func (api *API) CreatePerson(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var person models.Person
+	if !api.decodeJsonBody(w, r, &person) {
+		return
+	}
+	if !uuid.Equal(person.ID, uuid.Nil) {
+		api.writeErrorResponse(w, r, models.ProblemIDNotAllowed, "The ID of a new person is generated by the server and must not be provided", http.StatusBadRequest)
+		return
+	}
+	if !api.validatePerson(w, r, &person) {
+		return
+	}
+
+	revision, err := api.store.Create(&person)
+	if err != nil {
+		log.Printf("Error creating person, %s\n", err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to create person.", http.StatusInternalServerError)
+		return
+	}
+
+	if !api.recordAudit(w, r, audit.ActionCreate, []uuid.UUID{person.ID}, nil, &person) {
+		return
+	}
+	w.Header().Set("Location", personLocation(person.ID))
+	api.writeRevisionHeaders(w, r, revision)
+	api.redact(r, &person)
+	api.writeResponse(w, r, &person, http.StatusCreated)
+}
+
+func (api *API) ReplacePerson(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := api.parseID(w, r, ps)
+	if !ok {
+		return
+	}
+
+	var person models.Person
+	if !api.decodeJsonBody(w, r, &person) {
+		return
+	}
+	if !uuid.Equal(person.ID, uuid.Nil) && !uuid.Equal(person.ID, id) {
+		api.writeErrorResponse(w, r, models.ProblemIDMismatch, "The ID in the body does not match the ID in the path", http.StatusBadRequest)
+		return
+	}
+	person.ID = id
+	if !api.validatePerson(w, r, &person) {
+		return
+	}
+
+	before, revision, ok := api.findRevision(w, r, id)
+	if !ok {
+		return
+	}
+
+	api.updatePerson(w, r, &person, before, revision)
 }
 
-func (api *API) GetPerson(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+func (api *API) UpdatePerson(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := api.parseID(w, r, ps)
+	if !ok {
+		return
+	}
+
+	var patch models.PersonPatch
+	if !api.decodeJsonBody(w, r, &patch) {
+		return
+	}
+
+	before, revision, ok := api.findRevision(w, r, id)
+	if !ok {
+		return
+	}
+	person := *before
+	patch.Apply(&person)
+	if !api.validatePerson(w, r, &person) {
+		return
+	}
+
+	api.updatePerson(w, r, &person, before, revision)
+}
+
+func (api *API) DeletePerson(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := api.parseID(w, r, ps)
+	if !ok {
+		return
+	}
+
+	before, revision, ok := api.findRevision(w, r, id)
+	if !ok {
+		return
+	}
+
+	err := api.store.DeleteRevision(id, revision.Version)
+	if errors.Is(err, models.ErrPersonNotFound) {
+		api.writeErrorResponse(w, r, models.ProblemPersonNotFound, "Person with the provided ID was not found.", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrRevisionMismatch) {
+		api.writePreconditionFailed(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting person %s, %s\n", id.String(), err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to delete person.", http.StatusInternalServerError)
+		return
+	}
+	if !api.recordAudit(w, r, audit.ActionDelete, []uuid.UUID{id}, before, nil) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseQuery builds a query from every search parameter, rejecting any parameter that isn't a filter or used for paging
+func parseQuery(r *http.Request) (models.Query, error) {
+	query := models.Query{Filters: make([]models.Filter, 0)}
+	if err := r.ParseForm(); err != nil {
+		return query, err
+	}
+
+	for param, values := range r.Form {
+		if isPageParam(param) {
+			continue
+		}
+
+		field := strings.TrimSuffix(param, "~")
+		switch field {
+		case models.FieldFirstName, models.FieldLastName, models.FieldPhoneNumber:
+		default:
+			return query, fmt.Errorf("unknown parameter %q, must filter by first_name, last_name or phone_number", param)
+		}
+
+		for _, value := range values {
+			filter, err := models.NewFilter(field, value, field != param)
+			if err != nil {
+				return query, err
+			}
+			query.Filters = append(query.Filters, filter)
+		}
+	}
+	return query, nil
+}
+
+// usesPhoneNumber reports whether the query filters, or the page sorts, by phone number
+func usesPhoneNumber(query models.Query, p page) bool {
+	for _, filter := range query.Filters {
+		if filter.Field == models.FieldPhoneNumber {
+			return true
+		}
+	}
+	for _, field := range p.sort {
+		if field.Field == models.FieldPhoneNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePerson stores the validated replacement for before and writes it as the response. The update only applies if
+// before is still at revision, so a concurrent change can't be overwritten.
+func (api *API) updatePerson(w http.ResponseWriter, r *http.Request, person, before *models.Person, revision models.Revision) {
+	revision, err := api.store.UpdateRevision(person, revision.Version)
+	if errors.Is(err, models.ErrPersonNotFound) {
+		api.writeErrorResponse(w, r, models.ProblemPersonNotFound, "Person with the provided ID was not found.", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrRevisionMismatch) {
+		api.writePreconditionFailed(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error updating person %s, %s\n", person.ID.String(), err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to update person.", http.StatusInternalServerError)
+		return
+	}
+
+	if !api.recordAudit(w, r, audit.ActionUpdate, []uuid.UUID{person.ID}, before, person) {
+		return
+	}
+	api.writeRevisionHeaders(w, r, revision)
+	api.redact(r, person)
+	api.writeResponse(w, r, person, http.StatusOK)
+}
+
+// validatePerson validates the person, writing a 400 response with the failed fields and returning false if invalid
+func (api *API) validatePerson(w http.ResponseWriter, r *http.Request, person *models.Person) bool {
+	err := person.Validate()
+	if err == nil {
+		return true
+	}
+
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		api.writeErrorDetailsResponse(w, r, models.ProblemValidationFailed, "Invalid person provided", validationErr.Details, http.StatusBadRequest)
+	} else {
+		api.writeErrorResponse(w, r, models.ProblemValidationFailed, fmt.Sprintf("Invalid person provided, %s", err.Error()), http.StatusBadRequest)
+	}
+	return false
+}
+
+// parseID parses the id path parameter, writing a 400 response and returning false if it is not a valid UUID
+func (api *API) parseID(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (uuid.UUID, bool) {
 	id, err := uuid.FromString(ps.ByName("id"))
 	if err != nil {
 		log.Printf("Error parsing provided id, %s\n", err.Error())
-		api.writeErrorResponse(w, "Invalid ID provided", http.StatusBadRequest)
+		api.writeErrorResponse(w, r, models.ProblemInvalidID, "Invalid ID provided", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// findRevision looks up the person and its revision by ID, writing a 404, 412 or 500 response and returning false if
+// it can't be found or doesn't meet the request's preconditions
+func (api *API) findRevision(w http.ResponseWriter, r *http.Request, id uuid.UUID) (*models.Person, models.Revision, bool) {
+	person, revision, err := api.store.FindRevisionByID(id)
+	if errors.Is(err, models.ErrPersonNotFound) {
+		api.writeErrorResponse(w, r, models.ProblemPersonNotFound, "Person with the provided ID was not found.", http.StatusNotFound)
+		return nil, revision, false
+	} else if err != nil {
+		log.Printf("Error finding person %s, %s\n", id.String(), err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to find person.", http.StatusInternalServerError)
+		return nil, revision, false
+	}
+
+	if preconditionFailed(r, revision) {
+		api.writeRevisionHeaders(w, r, revision)
+		api.writePreconditionFailed(w, r)
+		return nil, revision, false
+	}
+	return person, revision, true
+}
+
+// personLocation returns the URL path of the person with the given ID
+func personLocation(id uuid.UUID) string {
+	return "/people/" + id.String()
+}
+
+// FuzzySearchPeople ranks people by how closely their names and phone number match the free text `q` parameter,
+// tolerating typos, partial names and phone number fragments. Each result includes its score.
+func (api *API) FuzzySearchPeople(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	p, err := parsePage(r)
+	if err != nil {
+		api.writeErrorResponse(w, r, models.ProblemInvalidPaging, fmt.Sprintf("Invalid paging parameters provided, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if len(p.sort) > 0 {
+		api.writeErrorResponse(w, r, models.ProblemInvalidPaging, "Invalid paging parameters provided, results are ordered by score and can't be sorted", http.StatusBadRequest)
+		return
+	}
+
+	q := strings.TrimSpace(r.FormValue("q"))
+	if len(q) == 0 {
+		api.writeErrorResponse(w, r, models.ProblemInvalidSearch, "Invalid search parameters provided, q must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	if !api.requirePII(w, r, models.HasPhoneFragment(q), "search by phone number") {
 		return
 	}
 
-	person, err := models.FindPersonByID(id)
+	people, err := api.store.All()
 	if err != nil {
-		api.writeErrorResponse(w, "Person with the provided ID was not found.", http.StatusNotFound)
+		log.Printf("Error listing people for search, %s\n", err.Error())
+		api.writeErrorResponse(w, r, models.ProblemInternal, "Unable to search people.", http.StatusInternalServerError)
 		return
 	}
 
-	api.writeJsonResponse(w, person, http.StatusOK)
+	results := models.FuzzySearch(people, q)
+	api.writePageHeaders(w, r, p, len(results))
+	start, end := p.bounds(len(results))
+	page := make([]*models.Person, 0, end-start)
+	for _, result := range results[start:end] {
+		page = append(page, result.Person)
+	}
+	if !api.recordAuditPeople(w, r, audit.ActionSearch, page) {
+		return
+	}
+	api.redact(r, page...)
+	api.writeResponse(w, r, results[start:end], http.StatusOK)
 }
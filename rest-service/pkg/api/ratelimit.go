@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig sets how many requests each client may make to each route
+type RateLimitConfig struct {
+	// Default applies to routes without their own limit, the zero Limit is unlimited
+	Default ratelimit.Limit
+	// Routes overrides the limit of individual routes, keyed by the route pattern such as "/people/:id"
+	Routes map[string]ratelimit.Limit
+	// AuthFailures limits the failed authentication attempts from each remote address across every route, so
+	// credentials can't be guessed faster than it allows. Requests without credentials aren't counted.
+	AuthFailures ratelimit.Limit
+}
+
+// WithRateLimits limits how often each client may call the people routes, clients are identified by their
+// authenticated principal or otherwise their remote address
+func WithRateLimits(config RateLimitConfig) Option {
+	return func(api *API) {
+		api.rateLimits = config
+	}
+}
+
+// rateLimiters holds the limiter of each route, shared by every method of the route
+type rateLimiters struct {
+	mu           sync.Mutex
+	limiters     map[string]*ratelimit.Limiter
+	authFailures *ratelimit.Limiter
+}
+
+// limiter returns the route's limiter, creating it on first use
+func (api *API) limiter(route string) *ratelimit.Limiter {
+	api.limiters.mu.Lock()
+	defer api.limiters.mu.Unlock()
+
+	if limiter, ok := api.limiters.limiters[route]; ok {
+		return limiter
+	}
+	limit, ok := api.rateLimits.Routes[route]
+	if !ok {
+		limit = api.rateLimits.Default
+	}
+	if api.limiters.limiters == nil {
+		api.limiters.limiters = make(map[string]*ratelimit.Limiter)
+	}
+	api.limiters.limiters[route] = ratelimit.New(limit)
+	return api.limiters.limiters[route]
+}
+
+// authFailureLimiter returns the limiter of failed authentication attempts, creating it on first use
+func (api *API) authFailureLimiter() *ratelimit.Limiter {
+	api.limiters.mu.Lock()
+	defer api.limiters.mu.Unlock()
+
+	if api.limiters.authFailures == nil {
+		api.limiters.authFailures = ratelimit.New(api.rateLimits.AuthFailures)
+	}
+	return api.limiters.authFailures
+}
+
+// RateLimit rejects requests from clients that have used up their allowance for the route with a 429. Every limited
+// response carries the IETF draft RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and rejected
+// requests a Retry-After, all in seconds.
+func (api *API) RateLimit(route string, handler httprouter.Handle) httprouter.Handle {
+	limiter := api.limiter(route)
+	if limiter.Limit().Unlimited() {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		result := limiter.Allow(rateLimitKey(r))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			api.writeRateLimited(w, r, "Too many requests", result)
+			return
+		}
+		handler(w, r, ps)
+	}
+}
+
+// writeRateLimited writes the 429 response of a request rejected by a limiter
+func (api *API) writeRateLimited(w http.ResponseWriter, r *http.Request, reason string, result ratelimit.Result) {
+	retryAfter := seconds(result.RetryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	api.writeErrorResponse(w, r, models.ProblemRateLimited, fmt.Sprintf("%s, retry in %d seconds", reason, retryAfter), http.StatusTooManyRequests)
+}
+
+// rateLimitKey identifies the client making the request, by principal if authenticated otherwise by remote address
+func rateLimitKey(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return "principal:" + principal.Method + ":" + principal.Subject
+	}
+	return remoteAddrKey(r)
+}
+
+// remoteAddrKey identifies the client making the request by its remote address
+func remoteAddrKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
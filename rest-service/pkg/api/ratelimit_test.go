@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPI_RateLimit(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "a", Key: "key-a", Scopes: []string{ScopeRead}},
+		{Name: "b", Key: "key-b", Scopes: []string{ScopeRead}},
+	}})
+	assert.Nil(t, err)
+
+	request := func(router http.Handler, path, key, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remoteAddr
+		if len(key) > 0 {
+			r.Header.Set(auth.APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Limited By API Key", func(t *testing.T) {
+		api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+			WithAuthenticator(authenticator), WithRateLimits(RateLimitConfig{Default: ratelimit.Limit{Rate: 0.5, Burst: 2}}))
+		router := api.Router()
+
+		w := request(router, "/people", "key-a", "192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+
+		// The same key from another address shares the allowance
+		w = request(router, "/people", "key-a", "192.0.2.2:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+		w = request(router, "/people", "key-a", "192.0.2.1:1234")
+		var response models.Error
+		assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&response))
+		_ = w.Result().Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+		assert.Equal(t, models.ProblemRateLimited, response.Code)
+		assert.Equal(t, "Too many requests, retry in 2 seconds", response.Detail)
+
+		assert.Equal(t, http.StatusOK, request(router, "/people", "key-b", "192.0.2.1:1234").Code)
+	})
+	t.Run("Limited By Remote Address", func(t *testing.T) {
+		api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+			WithRateLimits(RateLimitConfig{Default: ratelimit.Limit{Rate: 1, Burst: 1}}))
+		router := api.Router()
+
+		assert.Equal(t, http.StatusOK, request(router, "/people", "", "192.0.2.1:1234").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(router, "/people", "", "192.0.2.1:5678").Code)
+		assert.Equal(t, http.StatusOK, request(router, "/people", "", "192.0.2.2:1234").Code)
+	})
+	t.Run("Failed Authentication Limited By Remote Address", func(t *testing.T) {
+		api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+			WithAuthenticator(authenticator), WithRateLimits(RateLimitConfig{AuthFailures: ratelimit.Limit{Rate: 1.0 / 60, Burst: 3}}))
+		router := api.Router()
+
+		// Requests without credentials aren't guesses
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusUnauthorized, request(router, "/people", "", "192.0.2.1:1234").Code)
+		}
+		// Guesses against any route count towards the same allowance
+		for _, path := range []string{"/people", "/people/search?q=john", "/people"} {
+			assert.Equal(t, http.StatusUnauthorized, request(router, path, "guess", "192.0.2.1:1234").Code)
+		}
+
+		w := request(router, "/people", "guess", "192.0.2.1:5678")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		// Even a correct key is rejected until the address has waited
+		assert.Equal(t, http.StatusTooManyRequests, request(router, "/people", "key-a", "192.0.2.1:1234").Code)
+		assert.Equal(t, http.StatusOK, request(router, "/people", "key-a", "192.0.2.2:1234").Code)
+	})
+	t.Run("Per Route", func(t *testing.T) {
+		api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: ioutil.Discard}),
+			WithRateLimits(RateLimitConfig{Routes: map[string]ratelimit.Limit{"/people/search": {Rate: 1, Burst: 1}}}))
+		router := api.Router()
+
+		assert.Equal(t, http.StatusOK, request(router, "/people/search?q=john", "", "192.0.2.1:1234").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(router, "/people/search?q=john", "", "192.0.2.1:1234").Code)
+		for i := 0; i < 5; i++ {
+			w := request(router, "/people", "", "192.0.2.1:1234")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+	})
+	t.Run("Logged", func(t *testing.T) {
+		var log bytes.Buffer
+		api := New(models.NewMemoryStore(models.SamplePeople()...), WithAccessLog(AccessLogConfig{Output: &log, Format: LogFormatLogfmt, Level: LevelInfo}),
+			WithRateLimits(RateLimitConfig{Default: ratelimit.Limit{Rate: 1, Burst: 1}}))
+		router := api.Router()
+
+		request(router, "/people", "", "192.0.2.1:1234")
+		request(router, "/people", "", "192.0.2.1:1234")
+		assert.Contains(t, log.String(), "level=warn")
+		assert.Contains(t, log.String(), "status=429")
+	})
+}
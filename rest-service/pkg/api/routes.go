@@ -0,0 +1,152 @@
+package api
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/encoding"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Subroutes dispatches requests where the named parameter equals one of the static segments to that segment's handler,
+// and every other request to fallback.
+//
+// httprouter doesn't allow a static segment and a parameter at the same position, e.g. `/people/search` alongside
+// `/people/:id`, so static routes are registered by resolving them inside the parameter's route instead.
+func Subroutes(param string, static map[string]httprouter.Handle, fallback httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if handler, ok := static[ps.ByName(param)]; ok {
+			handler(w, r, ps)
+			return
+		}
+		fallback(w, r, ps)
+	}
+}
+
+// Route is a method and path pattern served by Router
+type Route struct {
+	Method string
+	Path   string
+	// Scope is required of callers when authentication is enabled, empty for routes anyone may call
+	Scope string
+}
+
+// routeTable registers handlers with a router, recording each route
+type routeTable struct {
+	api     *API
+	router  *httprouter.Router
+	routes  []Route
+	methods customMethods
+}
+
+// customMethods routes AIP-136 style custom methods such as `POST /people:import`, keyed by path then method.
+//
+// httprouter treats a colon as the start of a parameter, so these paths can't be registered with it and are
+// dispatched from the router's NotFound handler instead.
+type customMethods map[string]map[string]httprouter.Handle
+
+// serve calls the handler of the request's custom method, responding with api's problem details when there is none
+func (c customMethods) serve(api *API, w http.ResponseWriter, r *http.Request) {
+	methods, ok := c[r.URL.Path]
+	if !ok {
+		api.writeErrorResponse(w, r, models.ProblemNotFound, fmt.Sprintf("Nothing is served at %s", r.URL.Path), http.StatusNotFound)
+		return
+	}
+	if handler, ok := methods[r.Method]; ok {
+		handler(w, r, nil)
+		return
+	}
+
+	allowed := make([]string, 0, len(methods))
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	api.writeErrorResponse(w, r, models.ProblemMethodNotAllowed, fmt.Sprintf("%s only allows %s", r.URL.Path, strings.Join(allowed, ", ")), http.StatusMethodNotAllowed)
+}
+
+func (t *routeTable) handle(method, path, scope string, handler httprouter.Handle) {
+	t.router.Handle(method, path, handler)
+	t.record(method, path, scope)
+}
+
+// handleCustom registers the handler of a custom method and records the route
+func (t *routeTable) handleCustom(method, path, scope string, handler httprouter.Handle) {
+	if t.methods == nil {
+		t.methods = make(customMethods)
+		t.router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.methods.serve(t.api, w, r)
+		})
+	}
+	if _, ok := t.methods[path]; !ok {
+		t.methods[path] = make(map[string]httprouter.Handle)
+	}
+	t.methods[path][method] = handler
+	t.record(method, path, scope)
+}
+
+// record adds a route that is resolved by Subroutes rather than registered with the router
+func (t *routeTable) record(method, path, scope string) {
+	t.routes = append(t.routes, Route{Method: method, Path: path, Scope: scope})
+}
+
+// Router returns a router with every API route registered
+func (api *API) Router() *httprouter.Router {
+	return api.routeTable().router
+}
+
+// Routes returns every route Router registers, including those resolved by Subroutes
+func (api *API) Routes() []Route {
+	return api.routeTable().routes
+}
+
+func (api *API) routeTable() *routeTable {
+	t := &routeTable{api: api, router: httprouter.New()}
+	api.handle(t, http.MethodGet, "/people", ScopeRead, api.SearchPeople)
+	api.handle(t, http.MethodPost, "/people", ScopeWrite, api.CreatePerson)
+	static := map[string]httprouter.Handle{
+		"search": api.middleware("/people/search", ScopeRead, api.FuzzySearchPeople),
+	}
+	if api.events != nil {
+		static["events"] = api.middlewareFor("/people/events", ScopeRead, api.eventFormats, api.StreamEvents)
+	}
+	t.handle(http.MethodGet, "/people/:id", ScopeRead, api.RequestLogger(Subroutes("id", static, api.middleware("/people/:id", ScopeRead, api.GetPerson))))
+	t.record(http.MethodGet, "/people/search", ScopeRead)
+	if api.events != nil {
+		t.record(http.MethodGet, "/people/events", ScopeRead)
+	}
+	api.handle(t, http.MethodPut, "/people/:id", ScopeWrite, api.ReplacePerson)
+	api.handle(t, http.MethodPatch, "/people/:id", ScopeWrite, api.UpdatePerson)
+	api.handle(t, http.MethodDelete, "/people/:id", ScopeWrite, api.DeletePerson)
+	t.handleCustom(http.MethodPost, "/people:import", ScopeWrite, api.RequestLogger(api.middleware("/people:import", ScopeWrite, api.ImportPeople)))
+	t.handleCustom(http.MethodGet, "/people:export", ScopeRead, api.RequestLogger(api.middlewareFor("/people:export", ScopeRead, api.exportFormats, api.ExportPeople)))
+
+	t.handle(http.MethodGet, "/metrics", "", api.RequestLogger(api.ServeMetrics))
+	// Probes are polled constantly so they are left out of the access log and request metrics
+	t.handle(http.MethodGet, "/healthz", "", api.Liveness)
+	t.handle(http.MethodGet, "/readyz", "", api.Readiness)
+
+	// The specification describes every route including itself, so it is built last
+	t.record(http.MethodGet, "/openapi.json", "")
+	t.router.GET("/openapi.json", api.RequestLogger(api.serveOpenAPI(t.routes)))
+	return t
+}
+
+// handle registers handler for the route, requiring scope, wrapped in the full middleware chain
+func (api *API) handle(t *routeTable, method, route, scope string, handler httprouter.Handle) {
+	t.handle(method, route, scope, api.RequestLogger(api.middleware(route, scope, handler)))
+}
+
+// middleware wraps handler in the middleware that applies to each route individually. RequestLogger is applied
+// separately as it wraps every request, including ones resolved by Subroutes.
+func (api *API) middleware(route, scope string, handler httprouter.Handle) httprouter.Handle {
+	return api.middlewareFor(route, scope, api.encodings, handler)
+}
+
+// middlewareFor is middleware for routes whose responses are negotiated from their own formats
+func (api *API) middlewareFor(route, scope string, formats *encoding.Registry, handler httprouter.Handle) httprouter.Handle {
+	return api.Metrics(route, api.negotiate(formats, api.Authenticate(api.RateLimit(route, api.Authorize(scope, handler)))))
+}
@@ -0,0 +1,247 @@
+// Package audit writes a tamper-evident, append-only audit trail of data access as hash-chained JSON lines.
+//
+// Every entry includes the SHA-256 hash of the previous entry and a hash of itself, so changing, removing or
+// reordering any entry breaks the chain from that point on. Verify checks the whole chain. Removing entries from the
+// end can't be detected from the log alone, so keep the last hash Verify reports somewhere else to compare against.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ActionRead   = "read"
+	ActionSearch = "search"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionExport = "export"
+	ActionImport = "import"
+)
+
+// GenesisHash is the previous hash of the first entry in a log
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// maxLineSize is the longest entry Verify reads
+const maxLineSize = 1 << 20
+
+// ErrChainBroken is returned by Verify when an entry doesn't match its hash or doesn't follow the previous entry
+var ErrChainBroken = errors.New("audit chain broken")
+
+// Entry is a single audited access. Seq, Time, PrevHash and Hash are set by Record.
+type Entry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Actor is who made the request, the authenticated principal or "anonymous"
+	Actor      string `json:"actor"`
+	RemoteAddr string `json:"remote_addr"`
+	RequestID  string `json:"request_id,omitempty"`
+	Action     string `json:"action"`
+	// Resources are the IDs of every record that was read or changed
+	Resources []string `json:"resources"`
+	// Before and After are the record before and after a change
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	// Changes are the records before and after each change of an action that changes many records at once
+	Changes  []Change `json:"changes,omitempty"`
+	PrevHash string   `json:"prev_hash"`
+	Hash     string   `json:"hash"`
+}
+
+// Change is one of the records changed by an entry. Before is empty for a created record.
+type Change struct {
+	Resource string          `json:"resource"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+}
+
+// computeHash returns the hash of the entry with its Hash field cleared
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends entries to an audit log file. All functions are safe to call from multiple goroutines.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      uint64
+	lastHash string
+	// failed is set once an entry may have been partly written, later entries fail with it rather than being appended
+	// to a torn line
+	failed error
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// Open opens, or creates, the audit log at path. The existing chain is verified so new entries are only ever
+// appended to an intact log. A last line cut short while it was written is discarded, Record never returned for it.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log %s, %w", path, err)
+	}
+
+	result, tail, err := verify(file, true)
+	if err == nil && tail.line > 0 {
+		err = discard(file, tail)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error verifying audit log %s, %w", path, err)
+	}
+	return &Log{file: file, seq: result.Entries, lastHash: result.LastHash, now: time.Now}, nil
+}
+
+// discard truncates the incomplete line at the end of the log
+func discard(file *os.File, tail tornLine) error {
+	log.Printf("Discarding incomplete entry on line %d at the end of audit log %s\n", tail.line, file.Name())
+	if err := file.Truncate(tail.offset); err != nil {
+		return fmt.Errorf("error discarding incomplete line %d, %w", tail.line, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error discarding incomplete line %d, %w", tail.line, err)
+	}
+	return nil
+}
+
+// Record chains the entry onto the log and syncs it to disk
+func (l *Log) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.failed != nil {
+		return fmt.Errorf("audit log can no longer be written, %w", l.failed)
+	}
+
+	entry.Seq = l.seq + 1
+	entry.Time = l.now().UTC()
+	entry.PrevHash = l.lastHash
+	if entry.Resources == nil {
+		entry.Resources = []string{}
+	}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return fmt.Errorf("error hashing audit entry, %w", err)
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry, %w", err)
+	}
+	// A failed write may leave part of the line behind, anything appended after it would break the chain
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		l.failed = fmt.Errorf("error writting audit entry, %w", err)
+		return l.failed
+	}
+	if err := l.file.Sync(); err != nil {
+		l.failed = fmt.Errorf("error syncing audit log, %w", err)
+		return l.failed
+	}
+
+	l.seq = entry.Seq
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// Close closes the log file, the log must not be used afterwards
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// VerifyResult describes an intact chain
+type VerifyResult struct {
+	Entries  uint64
+	LastHash string
+}
+
+// Verify reads every entry from source and checks that each one matches its hash, follows the previous entry's hash
+// and has the next sequence number. Errors for a broken chain wrap ErrChainBroken and name the first bad line.
+func Verify(source io.Reader) (VerifyResult, error) {
+	result, _, err := verify(source, false)
+	return result, err
+}
+
+// tornLine is the last line of a log when it doesn't end with a newline, offset is where it starts
+type tornLine struct {
+	line   int
+	offset int64
+}
+
+// verify is Verify that, when allowTorn is set, returns the last line rather than checking it if it doesn't end with a
+// newline
+func verify(source io.Reader, allowTorn bool) (VerifyResult, tornLine, error) {
+	result := VerifyResult{LastHash: GenesisHash}
+	reader := bufio.NewReader(source)
+	var offset int64
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return result, tornLine{}, fmt.Errorf("error reading audit log, %w", err)
+		}
+		if len(data) == 0 {
+			return result, tornLine{}, nil
+		}
+		if len(data) > maxLineSize {
+			return result, tornLine{}, fmt.Errorf("line %d is longer than %d bytes, %w", line, maxLineSize, ErrChainBroken)
+		}
+		if data[len(data)-1] != '\n' && allowTorn {
+			return result, tornLine{line: line, offset: offset}, nil
+		}
+		offset += int64(len(data))
+
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return result, tornLine{}, fmt.Errorf("line %d is not a valid entry, %s, %w", line, err.Error(), ErrChainBroken)
+		}
+
+		if entry.Seq != result.Entries+1 {
+			return result, tornLine{}, fmt.Errorf("line %d has sequence %d, expected %d, %w", line, entry.Seq, result.Entries+1, ErrChainBroken)
+		}
+		if entry.PrevHash != result.LastHash {
+			return result, tornLine{}, fmt.Errorf("line %d does not follow the previous entry, %w", line, ErrChainBroken)
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return result, tornLine{}, fmt.Errorf("error hashing line %d, %w", line, err)
+		}
+		if hash != entry.Hash {
+			return result, tornLine{}, fmt.Errorf("line %d has been modified, %w", line, ErrChainBroken)
+		}
+
+		result.Entries = entry.Seq
+		result.LastHash = entry.Hash
+	}
+}
+
+// VerifyFile verifies the audit log at path
+func VerifyFile(path string) (VerifyResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("error opening audit log %s, %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return Verify(file)
+}
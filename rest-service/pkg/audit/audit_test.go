@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestLog(t *testing.T, entries int) string {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	assert.Nil(t, err)
+	log.now = func() time.Time { return time.Unix(1600000000, 0) }
+
+	for i := 0; i < entries; i++ {
+		assert.Nil(t, log.Record(Entry{
+			Actor:     "api_key:reader",
+			Action:    ActionUpdate,
+			Resources: []string{"81eb745b-3aae-400b-959f-748fcafafd81"},
+			Before:    json.RawMessage(`{"last_name": "Doe"}`),
+			After:     json.RawMessage(`{"last_name": "Roe"}`),
+		}))
+	}
+	assert.Nil(t, log.Close())
+	return path
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	assert.Nil(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+}
+
+func TestLog(t *testing.T) {
+	t.Run("Chained", func(t *testing.T) {
+		path := writeTestLog(t, 3)
+		lines := readLines(t, path)
+		assert.Len(t, lines, 3)
+
+		var first, second Entry
+		assert.Nil(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.Equal(t, uint64(1), first.Seq)
+		assert.Equal(t, GenesisHash, first.PrevHash)
+		assert.Equal(t, first.Hash, second.PrevHash)
+		assert.Equal(t, time.Unix(1600000000, 0).UTC(), first.Time)
+		assert.Equal(t, `{"last_name":"Roe"}`, string(first.After))
+
+		result, err := VerifyFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), result.Entries)
+	})
+	t.Run("Reopen Continues Chain", func(t *testing.T) {
+		path := writeTestLog(t, 2)
+		log, err := Open(path)
+		assert.Nil(t, err)
+		assert.Nil(t, log.Record(Entry{Action: ActionRead}))
+		assert.Nil(t, log.Close())
+
+		result, err := VerifyFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), result.Entries)
+	})
+	t.Run("Refuses Broken Log", func(t *testing.T) {
+		path := writeTestLog(t, 2)
+		lines := readLines(t, path)
+		writeLines(t, path, lines[1:])
+
+		_, err := Open(path)
+		assert.ErrorIs(t, err, ErrChainBroken)
+	})
+	t.Run("Discards Torn Line", func(t *testing.T) {
+		path := writeTestLog(t, 2)
+		lines := readLines(t, path)
+		// The last entry was cut short while it was written
+		assert.Nil(t, ioutil.WriteFile(path, []byte(lines[0]+"\n"+lines[1][:len(lines[1])/2]), 0600))
+
+		log, err := Open(path)
+		assert.Nil(t, err)
+		assert.Nil(t, log.Record(Entry{Action: ActionRead}))
+		assert.Nil(t, log.Close())
+
+		result, err := VerifyFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(2), result.Entries)
+		assert.Len(t, readLines(t, path), 2)
+	})
+	t.Run("Refuses Entries After Failed Write", func(t *testing.T) {
+		log, err := Open(filepath.Join(t.TempDir(), "audit.log"))
+		assert.Nil(t, err)
+		assert.Nil(t, log.file.Close())
+
+		assert.NotNil(t, log.Record(Entry{Action: ActionRead}))
+		err = log.Record(Entry{Action: ActionRead})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "can no longer be written")
+	})
+}
+
+func TestVerify(t *testing.T) {
+	tamper := map[string]func(lines []string) []string{
+		"Modified": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "Roe", "Poe", 1)
+			return lines
+		},
+		"Removed": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+		"Reordered": func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+		"Invalid JSON": func(lines []string) []string {
+			lines[1] = "{"
+			return lines
+		},
+	}
+	for name, modify := range tamper {
+		t.Run(name, func(t *testing.T) {
+			path := writeTestLog(t, 3)
+			writeLines(t, path, modify(readLines(t, path)))
+
+			result, err := VerifyFile(path)
+			assert.ErrorIs(t, err, ErrChainBroken)
+			assert.Contains(t, err.Error(), "line 2")
+			assert.Equal(t, uint64(1), result.Entries)
+		})
+	}
+	t.Run("Rehashed Entry", func(t *testing.T) {
+		path := writeTestLog(t, 3)
+		lines := readLines(t, path)
+		var entry Entry
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+		entry.Actor = "someone else"
+		entry.Hash, _ = entry.computeHash()
+		line, _ := json.Marshal(entry)
+		lines[1] = string(line)
+		writeLines(t, path, lines)
+
+		_, err := VerifyFile(path)
+		assert.ErrorIs(t, err, ErrChainBroken)
+		assert.Contains(t, err.Error(), "line 3")
+	})
+	t.Run("Empty", func(t *testing.T) {
+		result, err := Verify(strings.NewReader(""))
+
+		assert.Nil(t, err)
+		assert.Equal(t, VerifyResult{LastHash: GenesisHash}, result)
+	})
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := VerifyFile(filepath.Join(os.TempDir(), "does-not-exist", "audit.log"))
+
+		assert.NotNil(t, err)
+	})
+}
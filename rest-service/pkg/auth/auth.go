@@ -0,0 +1,227 @@
+// Package auth authenticates API callers with static API keys or HMAC-signed JWT bearer tokens.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader is the request header API keys are read from
+const APIKeyHeader = "X-API-Key"
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrMissingCredentials is returned when the request has no API key or bearer token
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned for unknown API keys and tokens that fail verification
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrDisabled is returned for credentials that are valid but have been disabled
+	ErrDisabled = errors.New("credentials disabled")
+)
+
+// Principal is the authenticated caller
+type Principal struct {
+	// Subject is the API key name or the token's sub claim
+	Subject string
+	// Method is how the principal authenticated, MethodAPIKey or MethodJWT
+	Method string
+	Scopes []string
+}
+
+// APIKey is a static key loaded from the config file
+type APIKey struct {
+	Name     string   `json:"name"`
+	Key      string   `json:"key"`
+	Scopes   []string `json:"scopes"`
+	Disabled bool     `json:"disabled"`
+}
+
+// JWTConfig configures verification of HMAC-signed bearer tokens
+type JWTConfig struct {
+	// Secret is the shared HMAC key, tokens are not accepted if it is empty
+	Secret string `json:"secret"`
+	// Issuer and Audience are required to match the iss and aud claims when set
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// Leeway allows for clock skew when checking the exp and nbf claims, e.g. "30s"
+	Leeway string `json:"leeway"`
+}
+
+// Config is the contents of the auth config file
+type Config struct {
+	APIKeys []APIKey  `json:"api_keys"`
+	JWT     JWTConfig `json:"jwt"`
+}
+
+// LoadConfig reads an auth config file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading auth config %s, %w", path, err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error parsing auth config %s, %w", path, err)
+	}
+	return config, nil
+}
+
+// Authenticator verifies the credentials of requests
+type Authenticator struct {
+	keys     []APIKey
+	secret   []byte
+	issuer   string
+	audience string
+	leeway   time.Duration
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// New validates config and creates an Authenticator from it
+func New(config Config) (*Authenticator, error) {
+	a := &Authenticator{
+		secret:   []byte(config.JWT.Secret),
+		issuer:   config.JWT.Issuer,
+		audience: config.JWT.Audience,
+		now:      time.Now,
+	}
+
+	if len(config.JWT.Leeway) > 0 {
+		leeway, err := time.ParseDuration(config.JWT.Leeway)
+		if err != nil || leeway < 0 {
+			return nil, fmt.Errorf("invalid jwt leeway %q", config.JWT.Leeway)
+		}
+		a.leeway = leeway
+	}
+
+	names := make(map[string]bool, len(config.APIKeys))
+	for _, key := range config.APIKeys {
+		if len(key.Name) == 0 || len(key.Key) == 0 {
+			return nil, fmt.Errorf("api keys must have a name and a key")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("duplicate api key name %q", key.Name)
+		}
+		names[key.Name] = true
+		a.keys = append(a.keys, key)
+	}
+	return a, nil
+}
+
+// Authenticate returns the principal identified by the request's API key or bearer token
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.AuthenticateHeader(r.Header)
+}
+
+// AuthenticateHeader returns the principal identified by the API key or bearer token in header, for protocols that
+// carry HTTP style headers such as gRPC metadata
+func (a *Authenticator) AuthenticateHeader(header http.Header) (*Principal, error) {
+	if key := header.Get(APIKeyHeader); len(key) > 0 {
+		return a.authenticateKey(key)
+	}
+
+	authorization := header.Get("Authorization")
+	if len(authorization) == 0 {
+		return nil, ErrMissingCredentials
+	}
+	scheme, token := authorization, ""
+	if i := strings.IndexByte(authorization, ' '); i >= 0 {
+		scheme, token = authorization[:i], strings.TrimSpace(authorization[i+1:])
+	}
+	if !strings.EqualFold(scheme, "Bearer") || len(token) == 0 {
+		return nil, fmt.Errorf("unsupported authorization scheme, %w", ErrInvalidCredentials)
+	}
+	return a.authenticateToken(token)
+}
+
+// authenticateKey compares key against every configured key in constant time
+func (a *Authenticator) authenticateKey(key string) (*Principal, error) {
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Key), []byte(key)) == 1 {
+			found = &a.keys[i]
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("unknown api key, %w", ErrInvalidCredentials)
+	}
+	if found.Disabled {
+		return nil, fmt.Errorf("api key %s, %w", found.Name, ErrDisabled)
+	}
+	return &Principal{Subject: found.Name, Method: MethodAPIKey, Scopes: append([]string(nil), found.Scopes...)}, nil
+}
+
+func (a *Authenticator) authenticateToken(token string) (*Principal, error) {
+	if len(a.secret) == 0 {
+		return nil, fmt.Errorf("bearer tokens are not enabled, %w", ErrInvalidCredentials)
+	}
+
+	claims, err := VerifyToken(token, a.secret)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", err.Error(), ErrInvalidCredentials)
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, fmt.Errorf("%s, %w", err.Error(), ErrInvalidCredentials)
+	}
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+// validateClaims checks the registered claims of a verified token
+func (a *Authenticator) validateClaims(claims Claims) error {
+	now := a.now()
+	if len(claims.Subject) == 0 {
+		return errors.New("token has no subject")
+	}
+	if claims.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(a.leeway)) {
+		return errors.New("token has expired")
+	}
+	if claims.NotBefore != 0 && now.Add(a.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if len(a.issuer) > 0 && claims.Issuer != a.issuer {
+		return errors.New("token issuer is not trusted")
+	}
+	if len(a.audience) > 0 && !claims.Audience.Contains(a.audience) {
+		return errors.New("token is not intended for this audience")
+	}
+	return nil
+}
+
+// principalContextKey is the context key of the authenticated principal
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal the request was authenticated as, or false if it wasn't authenticated
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
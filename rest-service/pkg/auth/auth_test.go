@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	authenticator, err := New(Config{
+		APIKeys: []APIKey{
+			{Name: "reader", Key: "reader-key", Scopes: []string{"people:read"}},
+			{Name: "retired", Key: "retired-key", Disabled: true},
+		},
+		JWT: JWTConfig{Secret: string(testSecret), Issuer: "https://issuer.example.com", Audience: "people-api", Leeway: "30s"},
+	})
+	assert.Nil(t, err)
+	authenticator.now = func() time.Time { return time.Unix(1600000000, 0) }
+	return authenticator
+}
+
+func testClaims() Claims {
+	return Claims{
+		Subject:   "jane",
+		Issuer:    "https://issuer.example.com",
+		Audience:  Audience{"people-api"},
+		ExpiresAt: 1600000060,
+		Scope:     "people:read people:write",
+	}
+}
+
+func authenticate(authenticator *Authenticator, header, value string) (*Principal, error) {
+	r := httptest.NewRequest(http.MethodGet, "/people", nil)
+	if len(header) > 0 {
+		r.Header.Set(header, value)
+	}
+	return authenticator.Authenticate(r)
+}
+
+func TestAuthenticator_APIKey(t *testing.T) {
+	authenticator := testAuthenticator(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		principal, err := authenticate(authenticator, APIKeyHeader, "reader-key")
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Principal{Subject: "reader", Method: MethodAPIKey, Scopes: []string{"people:read"}}, principal)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := authenticate(authenticator, APIKeyHeader, "reader-key2")
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := authenticate(authenticator, APIKeyHeader, "retired-key")
+
+		assert.ErrorIs(t, err, ErrDisabled)
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := authenticate(authenticator, "", "")
+
+		assert.ErrorIs(t, err, ErrMissingCredentials)
+	})
+}
+
+func TestAuthenticator_Token(t *testing.T) {
+	authenticator := testAuthenticator(t)
+	sign := func(claims Claims) string {
+		token, err := SignToken(claims, testSecret)
+		assert.Nil(t, err)
+		return "Bearer " + token
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		principal, err := authenticate(authenticator, "Authorization", sign(testClaims()))
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Principal{Subject: "jane", Method: MethodJWT, Scopes: []string{"people:read", "people:write"}}, principal)
+	})
+	t.Run("Expired Within Leeway", func(t *testing.T) {
+		claims := testClaims()
+		claims.ExpiresAt = 1600000000 - 10
+
+		_, err := authenticate(authenticator, "Authorization", sign(claims))
+		assert.Nil(t, err)
+	})
+
+	invalid := map[string]func(claims *Claims){
+		"Expired":       func(claims *Claims) { claims.ExpiresAt = 1600000000 - 31 },
+		"No Expiry":     func(claims *Claims) { claims.ExpiresAt = 0 },
+		"Not Yet Valid": func(claims *Claims) { claims.NotBefore = 1600000060 },
+		"Wrong Issuer":  func(claims *Claims) { claims.Issuer = "https://evil.example.com" },
+		"Wrong Audience": func(claims *Claims) {
+			claims.Audience = Audience{"billing-api"}
+		},
+		"No Subject": func(claims *Claims) { claims.Subject = "" },
+	}
+	for name, modify := range invalid {
+		t.Run(name, func(t *testing.T) {
+			claims := testClaims()
+			modify(&claims)
+
+			_, err := authenticate(authenticator, "Authorization", sign(claims))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("Wrong Secret", func(t *testing.T) {
+		token, _ := SignToken(testClaims(), []byte("other-secret"))
+
+		_, err := authenticate(authenticator, "Authorization", "Bearer "+token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+	t.Run("Tampered Claims", func(t *testing.T) {
+		parts := strings.Split(strings.TrimPrefix(sign(testClaims()), "Bearer "), ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":1600000060,"aud":"people-api","iss":"https://issuer.example.com"}`))
+
+		_, err := authenticate(authenticator, "Authorization", "Bearer "+strings.Join(parts, "."))
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+	t.Run("Algorithm None", func(t *testing.T) {
+		parts := strings.Split(strings.TrimPrefix(sign(testClaims()), "Bearer "), ".")
+		parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+		_, err := authenticate(authenticator, "Authorization", "Bearer "+parts[0]+"."+parts[1]+".")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+	t.Run("Unsupported Scheme", func(t *testing.T) {
+		_, err := authenticate(authenticator, "Authorization", "Basic cmVhZGVyOmtleQ==")
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+	t.Run("Tokens Disabled", func(t *testing.T) {
+		authenticator, err := New(Config{})
+		assert.Nil(t, err)
+
+		_, err = authenticate(authenticator, "Authorization", sign(testClaims()))
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "auth.json")
+		assert.Nil(t, ioutil.WriteFile(path, []byte(`{"api_keys":[{"name":"ci","key":"abc","scopes":["people:read"]}],"jwt":{"secret":"s"}}`), 0600))
+
+		config, err := LoadConfig(path)
+		assert.Nil(t, err)
+		assert.Equal(t, Config{APIKeys: []APIKey{{Name: "ci", Key: "abc", Scopes: []string{"people:read"}}}, JWT: JWTConfig{Secret: "s"}}, config)
+	})
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+
+		assert.NotNil(t, err)
+	})
+	t.Run("Invalid Keys", func(t *testing.T) {
+		_, err := New(Config{APIKeys: []APIKey{{Name: "ci", Key: "a"}, {Name: "ci", Key: "b"}}})
+		assert.NotNil(t, err)
+
+		_, err = New(Config{APIKeys: []APIKey{{Name: "ci"}}})
+		assert.NotNil(t, err)
+
+		_, err = New(Config{JWT: JWTConfig{Leeway: "soon"}})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// signingAlgorithms are the supported HMAC JWT algorithms. Only HMAC is supported, so tokens claiming "none" or an
+// asymmetric algorithm are rejected rather than being verified with the wrong kind of key.
+var signingAlgorithms = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// Claims are the JWT claims used for authentication, times are seconds since the Unix epoch
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// Scope is a space separated list of scopes as in RFC 8693
+	Scope string `json:"scope,omitempty"`
+}
+
+// Audience is the aud claim, which may be a single string or an array of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains reports whether audience is one of the token's audiences
+func (a Audience) Contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// SignToken creates an HS256 signed token with the claims
+func SignToken(claims Claims, secret []byte) (string, error) {
+	header, err := json.Marshal(tokenHeader{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sign(sha256.New, signingInput, secret)), nil
+}
+
+// VerifyToken checks the signature of a compact serialized JWT and returns its claims. The claims themselves, such as
+// the expiry, are not validated.
+func VerifyToken(token string, secret []byte) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, fmt.Errorf("malformed token header, %w", err)
+	}
+	algorithm, ok := signingAlgorithms[header.Algorithm]
+	if !ok {
+		return claims, fmt.Errorf("unsupported token algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errors.New("malformed token signature")
+	}
+	if !hmac.Equal(signature, sign(algorithm, parts[0]+"."+parts[1], secret)) {
+		return claims, errors.New("invalid token signature")
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("malformed token claims, %w", err)
+	}
+	return claims, nil
+}
+
+func sign(algorithm func() hash.Hash, signingInput string, secret []byte) []byte {
+	mac := hmac.New(algorithm, secret)
+	_, _ = mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
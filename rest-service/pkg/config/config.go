@@ -0,0 +1,479 @@
+// Package config loads the settings of rest-service, layering defaults, a YAML or TOML file, environment variables and
+// command line flags, each overriding the last.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pelletier/go-toml"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/api"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/server"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/webhook"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variable of every setting, which is otherwise its flag name in upper snake case,
+// e.g. REST_SERVICE_LISTEN_ADDR for -listenAddr
+const EnvPrefix = "REST_SERVICE_"
+
+// Config is every setting of rest-service. Empty paths and addresses disable the feature they configure.
+type Config struct {
+	ListenAddr string `yaml:"listen_addr" toml:"listen_addr"`
+	// GRPCListenAddr is where the gRPC PeopleService listens, gRPC is only served when it is set
+	GRPCListenAddr string   `yaml:"grpc_listen_addr" toml:"grpc_listen_addr"`
+	TLS            TLS      `yaml:"tls" toml:"tls"`
+	Timeouts       Timeouts `yaml:"timeouts" toml:"timeouts"`
+	Log            Log      `yaml:"log" toml:"log"`
+	// DataFile is the JSON-lines file people are persisted to, the sample people are served from memory without one
+	DataFile string `yaml:"data_file" toml:"data_file"`
+	// AuthConfig is the JSON file of API keys and JWT settings, the people API is open to anyone without one
+	AuthConfig   string    `yaml:"auth_config" toml:"auth_config"`
+	AuditLog     string    `yaml:"audit_log" toml:"audit_log"`
+	RateLimit    RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	CacheControl string    `yaml:"cache_control" toml:"cache_control"`
+	Events       Events    `yaml:"events" toml:"events"`
+}
+
+// TLS is the certificate the REST and gRPC APIs are served with, plaintext is served when both files are empty
+type TLS struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+	// ClientCAFile is the CA bundle client certificates are verified against, clients don't need one without it
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+	// ReloadInterval is how often the certificate files are checked for changes, zero never reloads them
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval"`
+}
+
+// Timeouts are the limits of server.Config, written as durations such as 15s
+type Timeouts struct {
+	Read          time.Duration `yaml:"read" toml:"read"`
+	ReadHeader    time.Duration `yaml:"read_header" toml:"read_header"`
+	Write         time.Duration `yaml:"write" toml:"write"`
+	Idle          time.Duration `yaml:"idle" toml:"idle"`
+	Shutdown      time.Duration `yaml:"shutdown" toml:"shutdown"`
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+}
+
+// Log configures the access log, which is written to standard error without a file
+type Log struct {
+	Format    string `yaml:"format" toml:"format"`
+	Level     string `yaml:"level" toml:"level"`
+	AccessLog string `yaml:"access_log" toml:"access_log"`
+}
+
+// RateLimit holds limits in the format of ratelimit.ParseLimit
+type RateLimit struct {
+	Default string `yaml:"default" toml:"default"`
+	// Routes overrides the default of individual routes, keyed by route pattern or gRPC full method name
+	Routes map[string]string `yaml:"routes" toml:"routes"`
+	// AuthFailures limits the failed authentication attempts of each remote address
+	AuthFailures string `yaml:"auth_failures" toml:"auth_failures"`
+}
+
+// Events configures the changes to people streamed at /people/events and delivered to webhooks
+type Events struct {
+	// History is how many events are kept for clients and webhooks to resume from after falling behind
+	History int `yaml:"history" toml:"history"`
+	// Webhooks has no flags or environment variables, it can only be set in the config file
+	Webhooks Webhooks `yaml:"webhooks" toml:"webhooks"`
+}
+
+// Webhooks are the endpoints events are delivered to and how failed deliveries are retried, see webhook.Config
+type Webhooks struct {
+	Endpoints      []WebhookEndpoint `yaml:"endpoints" toml:"endpoints"`
+	MaxAttempts    int               `yaml:"max_attempts" toml:"max_attempts"`
+	InitialBackoff time.Duration     `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     time.Duration     `yaml:"max_backoff" toml:"max_backoff"`
+	Timeout        time.Duration     `yaml:"timeout" toml:"timeout"`
+}
+
+// WebhookEndpoint is a URL events are POSTed to, see webhook.Endpoint
+type WebhookEndpoint struct {
+	URL    string `yaml:"url" toml:"url"`
+	Secret string `yaml:"secret" toml:"secret"`
+	// Events are the types of event delivered, e.g. person.created, every type is delivered when empty
+	Events []string `yaml:"events" toml:"events"`
+	PII    bool     `yaml:"pii" toml:"pii"`
+}
+
+// redactedSecret replaces webhook secrets when the configuration is written
+const redactedSecret = "REDACTED"
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	defaults := server.DefaultConfig()
+	webhooks := webhook.DefaultConfig()
+	return Config{
+		ListenAddr: defaults.Addr,
+		TLS:        TLS{ReloadInterval: defaults.TLSReloadInterval},
+		Timeouts: Timeouts{
+			Read:          defaults.ReadTimeout,
+			ReadHeader:    defaults.ReadHeaderTimeout,
+			Write:         defaults.WriteTimeout,
+			Idle:          defaults.IdleTimeout,
+			Shutdown:      defaults.ShutdownTimeout,
+			ShutdownDelay: defaults.ShutdownDelay,
+		},
+		Log:          Log{Format: string(api.LogFormatLogfmt), Level: api.LevelInfo.String()},
+		RateLimit:    RateLimit{Routes: make(map[string]string), AuthFailures: "20/m"},
+		CacheControl: api.DefaultCacheControl,
+		Events: Events{
+			History: models.DefaultEventHistory,
+			Webhooks: Webhooks{
+				Endpoints:      make([]WebhookEndpoint, 0),
+				MaxAttempts:    webhooks.MaxAttempts,
+				InitialBackoff: webhooks.InitialBackoff,
+				MaxBackoff:     webhooks.MaxBackoff,
+				Timeout:        webhooks.Timeout,
+			},
+		},
+	}
+}
+
+// Load registers a flag for every setting on flags, alongside any the caller has already registered, and builds the
+// configuration from args. Defaults are overridden by the YAML or TOML file named by the -config flag or
+// REST_SERVICE_CONFIG, then by environment variables looked up with lookupEnv, then by args.
+func Load(flags *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	c := Default()
+	file := ""
+	flags.StringVar(&file, "config", file, "The YAML or TOML file settings are read from, overridden by environment variables and flags. Can also be set with "+EnvPrefix+"CONFIG.")
+	names := c.registerFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return c, err
+	}
+
+	// The flags are bound to c, so they are parsed again once the file and environment variables have been applied to
+	// take precedence over both
+	c = Default()
+	if len(file) == 0 {
+		file, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if len(file) > 0 {
+		if err := c.loadFile(file); err != nil {
+			return c, err
+		}
+	}
+	for _, name := range names {
+		if value, ok := lookupEnv(EnvName(name)); ok {
+			if err := flags.Set(name, value); err != nil {
+				return c, fmt.Errorf("invalid %s, %w", EnvName(name), err)
+			}
+		}
+	}
+	return c, flags.Parse(args)
+}
+
+// EnvName returns the environment variable of the setting with the flag name
+func EnvName(flagName string) string {
+	var name strings.Builder
+	name.WriteString(EnvPrefix)
+	for i, r := range flagName {
+		if unicode.IsUpper(r) && i > 0 {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+// registerFlags binds a flag to every setting of c, returning their names
+func (c *Config) registerFlags(flags *flag.FlagSet) []string {
+	var names []string
+	env := func(name string) string {
+		names = append(names, name)
+		return " Can also be set with " + EnvName(name) + "."
+	}
+
+	flags.StringVar(&c.ListenAddr, "listenAddr", c.ListenAddr, "The address to listen on."+env("listenAddr"))
+	flags.StringVar(&c.GRPCListenAddr, "grpcListenAddr", c.GRPCListenAddr, "The address the gRPC PeopleService listens on. If empty gRPC is not served."+env("grpcListenAddr"))
+	flags.StringVar(&c.TLS.CertFile, "tlsCert", c.TLS.CertFile, "The PEM certificate chain the REST and gRPC APIs are served with. If empty they are served in plaintext."+env("tlsCert"))
+	flags.StringVar(&c.TLS.KeyFile, "tlsKey", c.TLS.KeyFile, "The PEM private key of the TLS certificate."+env("tlsKey"))
+	flags.StringVar(&c.TLS.ClientCAFile, "tlsClientCA", c.TLS.ClientCAFile, "The PEM bundle of CAs clients must present a certificate signed by. If empty client certificates aren't required."+env("tlsClientCA"))
+	flags.DurationVar(&c.TLS.ReloadInterval, "tlsReloadInterval", c.TLS.ReloadInterval, "How often the TLS certificate and key files are checked for changes and reloaded. If zero they are only loaded at startup."+env("tlsReloadInterval"))
+	flags.DurationVar(&c.Timeouts.Read, "readTimeout", c.Timeouts.Read, "The maximum duration for reading an entire request, including the body."+env("readTimeout"))
+	flags.DurationVar(&c.Timeouts.ReadHeader, "readHeaderTimeout", c.Timeouts.ReadHeader, "The maximum duration for reading request headers."+env("readHeaderTimeout"))
+	flags.DurationVar(&c.Timeouts.Write, "writeTimeout", c.Timeouts.Write, "The maximum duration before timing out writes of the response."+env("writeTimeout"))
+	flags.DurationVar(&c.Timeouts.Idle, "idleTimeout", c.Timeouts.Idle, "The maximum time to wait for the next request on a keep-alive connection."+env("idleTimeout"))
+	flags.DurationVar(&c.Timeouts.Shutdown, "shutdownTimeout", c.Timeouts.Shutdown, "How long in-flight requests are given to finish when shutting down."+env("shutdownTimeout"))
+	flags.DurationVar(&c.Timeouts.ShutdownDelay, "shutdownDelay", c.Timeouts.ShutdownDelay, "How long to keep serving after readiness starts failing on shutdown, so load balancers can route around the instance."+env("shutdownDelay"))
+	flags.StringVar(&c.Log.AccessLog, "accessLog", c.Log.AccessLog, "The file access logs are appended to. If empty they are written to standard error."+env("accessLog"))
+	flags.StringVar(&c.Log.Format, "logFormat", c.Log.Format, "The access log format, either json or logfmt."+env("logFormat"))
+	flags.StringVar(&c.Log.Level, "logLevel", c.Log.Level, "The minimum access log level, one of debug, info, warn or error. Requests are logged at info, warn for client errors and error for server errors."+env("logLevel"))
+	flags.StringVar(&c.AuthConfig, "authConfig", c.AuthConfig, "The JSON file of API keys and JWT settings used to authenticate requests. If empty the people API is open to anyone."+env("authConfig"))
+	flags.StringVar(&c.RateLimit.Default, "rateLimit", c.RateLimit.Default, "The requests each client may make to each people route and gRPC method, e.g. 600/m or 10/s:20 to allow bursts of 20. If empty requests are not limited."+env("rateLimit"))
+	flags.Var((*routeLimitsFlag)(&c.RateLimit.Routes), "routeRateLimit", "Overrides the rate limit of a route as route=limit, e.g. /people/search=1/s. May be repeated or comma separated."+env("routeRateLimit"))
+	flags.StringVar(&c.RateLimit.AuthFailures, "authFailureRateLimit", c.RateLimit.AuthFailures, "The failed authentication attempts each remote address may make across every route before it is rejected whatever its credentials. If empty failures are not limited."+env("authFailureRateLimit"))
+	flags.StringVar(&c.AuditLog, "auditLog", c.AuditLog, "The file a hash-chained audit trail of every read and change of people is appended to. If empty nothing is audited. Check it with `rest-service audit verify <file>`."+env("auditLog"))
+	flags.StringVar(&c.CacheControl, "cacheControl", c.CacheControl, "The Cache-Control policy of individual people, prefixed with private when authentication is enabled."+env("cacheControl"))
+	flags.IntVar(&c.Events.History, "eventHistory", c.Events.History, "How many change events are kept for /people/events clients and webhooks to resume from after disconnecting or falling behind."+env("eventHistory"))
+	flags.StringVar(&c.DataFile, "dataFile", c.DataFile, "The JSON-lines file people are persisted to. If empty the sample people are served from memory."+env("dataFile"))
+	return names
+}
+
+// loadFile overrides c with the settings of a YAML or TOML file, chosen by its extension. Unknown settings are an
+// error so typos aren't silently ignored.
+func (c *Config) loadFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("error opening config file, %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); errors.Is(err, io.EOF) {
+			// The file is empty
+			err = nil
+		}
+	case ".toml":
+		err = toml.NewDecoder(file).Strict(true).Decode(c)
+	default:
+		return fmt.Errorf("unsupported config file %s, must be .yaml, .yml or .toml", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding config file %s, %w", name, err)
+	}
+	return nil
+}
+
+// Write writes the configuration as YAML, in the format accepted as a config file. Webhook secrets are redacted.
+func (c Config) Write(w io.Writer) error {
+	if len(c.Events.Webhooks.Endpoints) > 0 {
+		// Redacted in a copy, c shares the endpoints with the caller
+		endpoints := make([]WebhookEndpoint, len(c.Events.Webhooks.Endpoints))
+		for i, endpoint := range c.Events.Webhooks.Endpoints {
+			endpoint.Secret = redactedSecret
+			endpoints[i] = endpoint
+		}
+		c.Events.Webhooks.Endpoints = endpoints
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ValidationError lists every invalid setting of a Config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration, " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) add(setting string, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, setting+": "+fmt.Sprintf(format, args...))
+}
+
+// Validate checks every setting, returning a *ValidationError listing all problems or nil if valid
+func (c Config) Validate() error {
+	result := &ValidationError{}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		result.add("listen_addr", "%s", err.Error())
+	}
+	if _, _, err := net.SplitHostPort(c.GRPCListenAddr); len(c.GRPCListenAddr) > 0 && err != nil {
+		result.add("grpc_listen_addr", "%s", err.Error())
+	}
+
+	if len(c.TLS.CertFile) > 0 && len(c.TLS.KeyFile) == 0 {
+		result.add("tls.key_file", "must be set with tls.cert_file")
+	} else if len(c.TLS.KeyFile) > 0 && len(c.TLS.CertFile) == 0 {
+		result.add("tls.cert_file", "must be set with tls.key_file")
+	}
+	if len(c.TLS.ClientCAFile) > 0 && len(c.TLS.CertFile) == 0 {
+		result.add("tls.client_ca_file", "requires tls.cert_file and tls.key_file")
+	}
+	if c.TLS.ReloadInterval < 0 {
+		result.add("tls.reload_interval", "must not be negative")
+	}
+
+	for _, timeout := range []struct {
+		setting string
+		value   time.Duration
+	}{
+		{"timeouts.read", c.Timeouts.Read},
+		{"timeouts.read_header", c.Timeouts.ReadHeader},
+		{"timeouts.write", c.Timeouts.Write},
+		{"timeouts.idle", c.Timeouts.Idle},
+		{"timeouts.shutdown_delay", c.Timeouts.ShutdownDelay},
+	} {
+		if timeout.value < 0 {
+			result.add(timeout.setting, "must not be negative")
+		}
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		result.add("timeouts.shutdown", "must be positive")
+	}
+
+	if _, err := api.ParseLogFormat(c.Log.Format); err != nil {
+		result.add("log.format", "%s", err.Error())
+	}
+	if _, err := api.ParseLogLevel(c.Log.Level); err != nil {
+		result.add("log.level", "%s", err.Error())
+	}
+
+	if _, err := c.RateLimits(); err != nil {
+		result.add("rate_limit", "%s", err.Error())
+	}
+
+	if c.Events.History < 0 {
+		result.add("events.history", "must not be negative")
+	}
+	c.Events.Webhooks.validate(result)
+
+	if len(result.Problems) > 0 {
+		return result
+	}
+	return nil
+}
+
+// validate adds the problems of the webhook settings to result
+func (w Webhooks) validate(result *ValidationError) {
+	eventTypes := make(map[string]bool)
+	for _, eventType := range models.EventTypes() {
+		eventTypes[string(eventType)] = true
+	}
+	for i, endpoint := range w.Endpoints {
+		setting := fmt.Sprintf("events.webhooks.endpoints[%d]", i)
+		if u, err := url.Parse(endpoint.URL); err != nil {
+			result.add(setting+".url", "%s", err.Error())
+		} else if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			result.add(setting+".url", "must be an absolute http or https URL")
+		}
+		if len(endpoint.Secret) == 0 {
+			result.add(setting+".secret", "must be set so deliveries can be verified")
+		}
+		for _, eventType := range endpoint.Events {
+			if !eventTypes[eventType] {
+				result.add(setting+".events", "unknown event type %s", eventType)
+			}
+		}
+	}
+
+	if w.MaxAttempts < 1 {
+		result.add("events.webhooks.max_attempts", "must be at least 1")
+	}
+	if w.InitialBackoff <= 0 {
+		result.add("events.webhooks.initial_backoff", "must be positive")
+	}
+	if w.MaxBackoff < w.InitialBackoff {
+		result.add("events.webhooks.max_backoff", "must not be less than events.webhooks.initial_backoff")
+	}
+	if w.Timeout <= 0 {
+		result.add("events.webhooks.timeout", "must be positive")
+	}
+}
+
+// Server returns the settings of the REST API's server.Server
+func (c Config) Server() server.Config {
+	return server.Config{
+		Addr:              c.ListenAddr,
+		TLSCertFile:       c.TLS.CertFile,
+		TLSKeyFile:        c.TLS.KeyFile,
+		TLSClientCAFile:   c.TLS.ClientCAFile,
+		TLSReloadInterval: c.TLS.ReloadInterval,
+		ReadTimeout:       c.Timeouts.Read,
+		ReadHeaderTimeout: c.Timeouts.ReadHeader,
+		WriteTimeout:      c.Timeouts.Write,
+		IdleTimeout:       c.Timeouts.Idle,
+		ShutdownTimeout:   c.Timeouts.Shutdown,
+		ShutdownDelay:     c.Timeouts.ShutdownDelay,
+	}
+}
+
+// RateLimits parses the rate limits
+func (c Config) RateLimits() (api.RateLimitConfig, error) {
+	config := api.RateLimitConfig{Routes: make(map[string]ratelimit.Limit, len(c.RateLimit.Routes))}
+
+	limit, err := ratelimit.ParseLimit(c.RateLimit.Default)
+	if err != nil {
+		return config, err
+	}
+	config.Default = limit
+	if config.AuthFailures, err = ratelimit.ParseLimit(c.RateLimit.AuthFailures); err != nil {
+		return config, fmt.Errorf("auth failures, %w", err)
+	}
+
+	routes := make([]string, 0, len(c.RateLimit.Routes))
+	for route := range c.RateLimit.Routes {
+		routes = append(routes, route)
+	}
+	// Sorted so the same route is reported each time when several are invalid
+	sort.Strings(routes)
+	for _, route := range routes {
+		limit, err := ratelimit.ParseLimit(c.RateLimit.Routes[route])
+		if err != nil {
+			return config, fmt.Errorf("route %s, %w", route, err)
+		}
+		config.Routes[route] = limit
+	}
+	return config, nil
+}
+
+// Webhooks returns the settings of the webhook.Dispatcher
+func (c Config) Webhooks() webhook.Config {
+	config := webhook.Config{
+		Endpoints:      make([]webhook.Endpoint, 0, len(c.Events.Webhooks.Endpoints)),
+		MaxAttempts:    c.Events.Webhooks.MaxAttempts,
+		InitialBackoff: c.Events.Webhooks.InitialBackoff,
+		MaxBackoff:     c.Events.Webhooks.MaxBackoff,
+		Timeout:        c.Events.Webhooks.Timeout,
+	}
+	for _, endpoint := range c.Events.Webhooks.Endpoints {
+		events := make([]models.EventType, 0, len(endpoint.Events))
+		for _, eventType := range endpoint.Events {
+			events = append(events, models.EventType(eventType))
+		}
+		config.Endpoints = append(config.Endpoints, webhook.Endpoint{URL: endpoint.URL, Secret: endpoint.Secret, Events: events, PII: endpoint.PII})
+	}
+	return config
+}
+
+// routeLimitsFlag collects repeated or comma separated route=limit flags
+type routeLimitsFlag map[string]string
+
+func (f *routeLimitsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	values := make([]string, 0, len(*f))
+	for route, limit := range *f {
+		values = append(values, route+"="+limit)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+func (f *routeLimitsFlag) Set(value string) error {
+	if *f == nil {
+		*f = make(routeLimitsFlag)
+	}
+	for _, value := range strings.Split(value, ",") {
+		i := strings.LastIndexByte(value, '=')
+		if i < 1 {
+			return fmt.Errorf("must be route=limit, e.g. /people=10/s")
+		}
+		(*f)[strings.TrimSpace(value[:i])] = strings.TrimSpace(value[i+1:])
+	}
+	return nil
+}
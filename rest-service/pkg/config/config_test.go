@@ -0,0 +1,321 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+	load := func(args []string, env map[string]string) (Config, error) {
+		flags := flag.NewFlagSet("rest-service", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		return Load(flags, args, func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		})
+	}
+
+	yamlFile := write("config.yaml", `
+listen_addr: ":8443"
+tls:
+  cert_file: cert.pem
+  key_file: key.pem
+  client_ca_file: clients.pem
+timeouts:
+  read: 20s
+log:
+  format: json
+rate_limit:
+  default: 10/s
+  routes:
+    /people/search: 1/s
+`)
+	tomlFile := write("config.toml", `
+listen_addr = ":8443"
+data_file = "people.jsonl"
+
+[timeouts]
+write = "1m"
+
+[rate_limit.routes]
+"/people" = "5/s"
+`)
+
+	t.Run("Defaults", func(t *testing.T) {
+		c, err := load(nil, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Default(), c)
+		assert.Nil(t, c.Validate())
+	})
+	t.Run("YAML File", func(t *testing.T) {
+		c, err := load([]string{"-config", yamlFile}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, ":8443", c.ListenAddr)
+		assert.Equal(t, TLS{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "clients.pem", ReloadInterval: Default().TLS.ReloadInterval}, c.TLS)
+		assert.Equal(t, 20*time.Second, c.Timeouts.Read)
+		assert.Equal(t, Default().Timeouts.Write, c.Timeouts.Write)
+		assert.Equal(t, "json", c.Log.Format)
+		assert.Equal(t, Default().Log.Level, c.Log.Level)
+		assert.Equal(t, RateLimit{Default: "10/s", Routes: map[string]string{"/people/search": "1/s"}, AuthFailures: Default().RateLimit.AuthFailures}, c.RateLimit)
+	})
+	t.Run("TOML File", func(t *testing.T) {
+		c, err := load(nil, map[string]string{"REST_SERVICE_CONFIG": tomlFile})
+
+		assert.Nil(t, err)
+		assert.Equal(t, ":8443", c.ListenAddr)
+		assert.Equal(t, "people.jsonl", c.DataFile)
+		assert.Equal(t, time.Minute, c.Timeouts.Write)
+		assert.Equal(t, map[string]string{"/people": "5/s"}, c.RateLimit.Routes)
+	})
+	t.Run("Environment Overrides File", func(t *testing.T) {
+		c, err := load([]string{"-config", yamlFile}, map[string]string{
+			"REST_SERVICE_LISTEN_ADDR":      ":9443",
+			"REST_SERVICE_READ_TIMEOUT":     "30s",
+			"REST_SERVICE_ROUTE_RATE_LIMIT": "/people=2/s, /people/search=3/s",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, ":9443", c.ListenAddr)
+		assert.Equal(t, 30*time.Second, c.Timeouts.Read)
+		assert.Equal(t, "json", c.Log.Format)
+		assert.Equal(t, map[string]string{"/people": "2/s", "/people/search": "3/s"}, c.RateLimit.Routes)
+	})
+	t.Run("Flags Override Environment", func(t *testing.T) {
+		c, err := load([]string{"-config", yamlFile, "-listenAddr", ":10443", "-logFormat", "logfmt", "-routeRateLimit", "/people=4/s"},
+			map[string]string{"REST_SERVICE_LISTEN_ADDR": ":9443", "REST_SERVICE_LOG_FORMAT": "json"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, ":10443", c.ListenAddr)
+		assert.Equal(t, "logfmt", c.Log.Format)
+		assert.Equal(t, 20*time.Second, c.Timeouts.Read)
+		assert.Equal(t, map[string]string{"/people": "4/s", "/people/search": "1/s"}, c.RateLimit.Routes)
+	})
+	t.Run("Config Flag Overrides Environment", func(t *testing.T) {
+		c, err := load([]string{"-config", tomlFile}, map[string]string{"REST_SERVICE_CONFIG": yamlFile})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "people.jsonl", c.DataFile)
+	})
+	t.Run("Unknown Setting", func(t *testing.T) {
+		for name, contents := range map[string]string{"typo.yaml": "listen_adr: \":1\"\n", "typo.toml": "listen_adr = \":1\"\n"} {
+			_, err := load([]string{"-config", write(name, contents)}, nil)
+			assert.NotNil(t, err, name)
+		}
+	})
+	t.Run("Unsupported File", func(t *testing.T) {
+		_, err := load([]string{"-config", write("config.json", "{}")}, nil)
+		assert.NotNil(t, err)
+
+		_, err = load([]string{"-config", filepath.Join(dir, "missing.yaml")}, nil)
+		assert.NotNil(t, err)
+	})
+	t.Run("Empty File", func(t *testing.T) {
+		c, err := load([]string{"-config", write("empty.yaml", "")}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Default(), c)
+	})
+	t.Run("Webhooks", func(t *testing.T) {
+		expected := Default().Events.Webhooks
+		expected.Endpoints = []WebhookEndpoint{
+			{URL: "https://example.com/hooks", Secret: "s3cret", Events: []string{"person.deleted"}},
+			{URL: "http://localhost:9000/", Secret: "other", PII: true},
+		}
+		expected.MaxAttempts = 3
+
+		for name, contents := range map[string]string{
+			"webhooks.yaml": `
+events:
+  history: 50
+  webhooks:
+    max_attempts: 3
+    endpoints:
+      - url: https://example.com/hooks
+        secret: s3cret
+        events: [person.deleted]
+      - url: http://localhost:9000/
+        secret: other
+        pii: true
+`,
+			"webhooks.toml": `
+[events]
+history = 50
+
+[events.webhooks]
+max_attempts = 3
+
+[[events.webhooks.endpoints]]
+url = "https://example.com/hooks"
+secret = "s3cret"
+events = ["person.deleted"]
+
+[[events.webhooks.endpoints]]
+url = "http://localhost:9000/"
+secret = "other"
+pii = true
+`,
+		} {
+			c, err := load([]string{"-config", write(name, contents)}, map[string]string{"REST_SERVICE_EVENT_HISTORY": "100"})
+
+			assert.Nil(t, err, name)
+			assert.Equal(t, 100, c.Events.History, name)
+			assert.Equal(t, expected, c.Events.Webhooks, name)
+			assert.Nil(t, c.Validate(), name)
+		}
+	})
+	t.Run("Invalid Environment Variable", func(t *testing.T) {
+		_, err := load(nil, map[string]string{"REST_SERVICE_WRITE_TIMEOUT": "soon"})
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "REST_SERVICE_WRITE_TIMEOUT")
+	})
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "REST_SERVICE_LISTEN_ADDR", EnvName("listenAddr"))
+	assert.Equal(t, "REST_SERVICE_READ_HEADER_TIMEOUT", EnvName("readHeaderTimeout"))
+	assert.Equal(t, "REST_SERVICE_CONFIG", EnvName("config"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("Every Problem Listed", func(t *testing.T) {
+		c := Default()
+		c.ListenAddr = "8080"
+		c.TLS.CertFile = "cert.pem"
+		c.Timeouts.Read = -time.Second
+		c.Timeouts.Shutdown = 0
+		c.Log.Format = "xml"
+		c.Log.Level = "loud"
+		c.RateLimit.Routes["/people"] = "fast"
+
+		err := c.Validate()
+		assert.IsType(t, &ValidationError{}, err)
+		problems := err.(*ValidationError).Problems
+		assert.Len(t, problems, 7)
+		for i, setting := range []string{"listen_addr", "tls.key_file", "timeouts.read", "timeouts.shutdown", "log.format", "log.level", "rate_limit"} {
+			assert.Contains(t, problems[i], setting+": ")
+		}
+	})
+	t.Run("TLS Key Without Certificate", func(t *testing.T) {
+		c := Default()
+		c.TLS.KeyFile = "key.pem"
+
+		assert.Contains(t, c.Validate().Error(), "tls.cert_file")
+	})
+	t.Run("Client CA Without TLS", func(t *testing.T) {
+		c := Default()
+		c.TLS.ClientCAFile = "clients.pem"
+
+		assert.Contains(t, c.Validate().Error(), "tls.client_ca_file")
+	})
+	t.Run("Invalid Webhooks", func(t *testing.T) {
+		c := Default()
+		c.Events.History = -1
+		c.Events.Webhooks.Endpoints = []WebhookEndpoint{
+			{URL: "ftp://example.com", Secret: "s3cret"},
+			{URL: "https://example.com", Events: []string{"person.renamed"}},
+		}
+		c.Events.Webhooks.MaxAttempts = 0
+		c.Events.Webhooks.MaxBackoff = time.Millisecond
+
+		err := c.Validate()
+		assert.IsType(t, &ValidationError{}, err)
+		problems := err.(*ValidationError).Problems
+		assert.Len(t, problems, 6)
+		for i, setting := range []string{
+			"events.history", "events.webhooks.endpoints[0].url", "events.webhooks.endpoints[1].secret",
+			"events.webhooks.endpoints[1].events", "events.webhooks.max_attempts", "events.webhooks.max_backoff",
+		} {
+			assert.Contains(t, problems[i], setting+": ")
+		}
+	})
+	t.Run("gRPC Enabled", func(t *testing.T) {
+		c := Default()
+		assert.Empty(t, c.GRPCListenAddr)
+
+		c.GRPCListenAddr = ":9090"
+		assert.Nil(t, c.Validate())
+		c.GRPCListenAddr = "9090"
+		assert.NotNil(t, c.Validate())
+	})
+}
+
+func TestConfig_RateLimits(t *testing.T) {
+	c := Default()
+	c.RateLimit = RateLimit{Default: "10/s", Routes: map[string]string{"/people/search": "1/s:5"}, AuthFailures: "6/m"}
+
+	limits, err := c.RateLimits()
+
+	assert.Nil(t, err)
+	assert.Equal(t, ratelimit.Limit{Rate: 10, Burst: 10}, limits.Default)
+	assert.Equal(t, ratelimit.Limit{Rate: 1, Burst: 5}, limits.Routes["/people/search"])
+	assert.Equal(t, ratelimit.Limit{Rate: 0.1, Burst: 6}, limits.AuthFailures)
+
+	c.RateLimit.AuthFailures = "often"
+	_, err = c.RateLimits()
+	assert.NotNil(t, err)
+}
+
+func TestConfig_Webhooks(t *testing.T) {
+	c := Default()
+	c.Events.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://example.com/hooks", Secret: "s3cret", Events: []string{"person.created"}, PII: true}}
+
+	config := c.Webhooks()
+
+	assert.Equal(t, []webhook.Endpoint{
+		{URL: "https://example.com/hooks", Secret: "s3cret", Events: []models.EventType{models.EventPersonCreated}, PII: true},
+	}, config.Endpoints)
+	assert.Equal(t, webhook.DefaultConfig().MaxAttempts, config.MaxAttempts)
+	assert.Equal(t, webhook.DefaultConfig().Timeout, config.Timeout)
+}
+
+func TestConfig_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	c := Default()
+	c.Timeouts.Read = 90 * time.Second
+	c.RateLimit.Routes["/people"] = "5/s"
+	var buffer bytes.Buffer
+	assert.Nil(t, c.Write(&buffer))
+	assert.Contains(t, buffer.String(), "read: 1m30s\n")
+
+	// The printed configuration can be loaded back
+	path := filepath.Join(dir, "printed.yaml")
+	assert.Nil(t, ioutil.WriteFile(path, buffer.Bytes(), 0600))
+	loaded := Default()
+	assert.Nil(t, loaded.loadFile(path))
+	assert.Equal(t, c, loaded)
+
+	t.Run("Webhook Secrets Redacted", func(t *testing.T) {
+		c := Default()
+		c.Events.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://example.com/hooks", Secret: "s3cret"}}
+		var buffer bytes.Buffer
+		assert.Nil(t, c.Write(&buffer))
+
+		assert.NotContains(t, buffer.String(), "s3cret")
+		assert.Contains(t, buffer.String(), "secret: "+redactedSecret+"\n")
+		assert.Equal(t, "s3cret", c.Events.Webhooks.Endpoints[0].Secret)
+	})
+}
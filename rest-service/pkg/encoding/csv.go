@@ -0,0 +1,128 @@
+package encoding
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// csvColumn is a field of a struct written as a CSV column, index is the path to the field through embedded structs
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// EncodeCSV writes a struct, or a slice of structs, as CSV with a header row of the JSON field names. Fields holding
+// objects or lists are written as JSON, and embedded structs are flattened the way encoding/json does.
+func EncodeCSV(w io.Writer, v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+
+	rows := []reflect.Value{value}
+	elemType := value.Type()
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		rows = make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, value.Index(i))
+		}
+		elemType = value.Type().Elem()
+	}
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv can only encode structs or lists of structs, not %s", value.Type())
+	}
+
+	columns := csvColumns(elemType, nil)
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, 0, len(columns))
+		for _, column := range columns {
+			cell, err := csvCell(row, column.index)
+			if err != nil {
+				return fmt.Errorf("error encoding csv column %s, %w", column.name, err)
+			}
+			record = append(record, cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns returns the columns of a struct type named by their JSON tags
+func csvColumns(structType reflect.Type, index []int) []csvColumn {
+	columns := make([]csvColumn, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && len(name) == 0 {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				columns = append(columns, csvColumns(embedded, fieldIndex)...)
+				continue
+			}
+		}
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: fieldIndex})
+	}
+	return columns
+}
+
+// csvCell formats the field at index of row, following embedded pointers. Missing values are written as empty cells.
+func csvCell(row reflect.Value, index []int) (string, error) {
+	value := row
+	for _, i := range index {
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return "", nil
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+
+	encoded, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case string(encoded) == "null":
+		return "", nil
+	case encoded[0] == '"':
+		var text string
+		err := json.Unmarshal(encoded, &text)
+		return text, err
+	}
+	return string(encoded), nil
+}
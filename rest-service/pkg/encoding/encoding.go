@@ -0,0 +1,188 @@
+// Package encoding serializes responses in the media type negotiated from a request's Accept header.
+package encoding
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeCSV     = "text/csv"
+	MediaTypeXML     = "application/xml"
+	MediaTypeMsgpack = "application/msgpack"
+
+	// MediaTypeProblemJSON and MediaTypeProblemXML are the RFC 7807 problem details forms of JSON and XML
+	MediaTypeProblemJSON = "application/problem+json"
+	MediaTypeProblemXML  = "application/problem+xml"
+)
+
+// Encoder writes v to w
+type Encoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// EncoderFunc adapts a function to an Encoder
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+func (f EncoderFunc) Encode(w io.Writer, v interface{}) error {
+	return f(w, v)
+}
+
+// Format is a registered encoder and the media type it produces
+type Format struct {
+	// MediaType is matched against Accept headers, e.g. "text/csv"
+	MediaType string
+	// ContentType is sent as the response Content-Type, e.g. "text/csv; charset=utf-8"
+	ContentType string
+	Encoder     Encoder
+	// aliases are other media types clients may ask for this format by
+	aliases []string
+}
+
+// ProblemContentType returns the Content-Type of RFC 7807 problem details encoded in the format. Formats without a
+// problem media type use their usual Content-Type.
+func (f Format) ProblemContentType() string {
+	switch f.MediaType {
+	case MediaTypeJSON, MediaTypeNDJSON, MediaTypeEventStream:
+		// A single line of NDJSON is plain JSON, and event streams can't carry errors so they are sent as JSON
+		return MediaTypeProblemJSON
+	case MediaTypeXML:
+		return MediaTypeProblemXML + "; charset=utf-8"
+	}
+	return f.ContentType
+}
+
+// Registry holds the formats responses can be encoded in. Formats are preferred in the order they were registered
+// when a client accepts several equally.
+type Registry struct {
+	formats []Format
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry returns a registry of JSON, CSV, XML and MessagePack with JSON preferred
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(MediaTypeJSON, MediaTypeJSON, EncoderFunc(EncodeJSON))
+	registry.Register(MediaTypeCSV, MediaTypeCSV+"; charset=utf-8", EncoderFunc(EncodeCSV))
+	registry.Register(MediaTypeXML, MediaTypeXML+"; charset=utf-8", EncoderFunc(EncodeXML), "text/xml")
+	registry.Register(MediaTypeMsgpack, MediaTypeMsgpack, EncoderFunc(EncodeMsgpack), "application/x-msgpack", "application/vnd.msgpack")
+	return registry
+}
+
+// Register adds a format, replacing any already registered for the media type
+func (r *Registry) Register(mediaType, contentType string, encoder Encoder, aliases ...string) {
+	format := Format{MediaType: mediaType, ContentType: contentType, Encoder: encoder, aliases: aliases}
+	for i := range r.formats {
+		if r.formats[i].MediaType == mediaType {
+			r.formats[i] = format
+			return
+		}
+	}
+	r.formats = append(r.formats, format)
+}
+
+// MediaTypes returns the media type of every registered format in order of preference
+func (r *Registry) MediaTypes() []string {
+	mediaTypes := make([]string, 0, len(r.formats))
+	for _, format := range r.formats {
+		mediaTypes = append(mediaTypes, format.MediaType)
+	}
+	return mediaTypes
+}
+
+// Default returns the most preferred format
+func (r *Registry) Default() Format {
+	return r.formats[0]
+}
+
+// Negotiate picks the format the client prefers from an Accept header as described in RFC 7231 section 5.3.2.
+// Each format gets the quality of the most specific media range matching it, and the highest quality wins. A missing
+// header accepts anything. Returns false when no registered format is acceptable.
+func (r *Registry) Negotiate(accept string) (Format, bool) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return r.Default(), true
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQuality := -1, 0.0
+	for i, format := range r.formats {
+		quality := 0.0
+		specificity := -1
+		for _, mediaType := range append([]string{format.MediaType}, format.aliases...) {
+			for _, mediaRange := range ranges {
+				if s := mediaRange.matches(mediaType); s > specificity {
+					specificity, quality = s, mediaRange.quality
+				}
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = i, quality
+		}
+	}
+
+	if best < 0 {
+		return Format{}, false
+	}
+	return r.formats[best], true
+}
+
+// mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mainType string
+	subType  string
+	quality  float64
+}
+
+// matches returns how specifically the range matches mediaType, 2 for an exact match, 1 for type/*, 0 for */* and
+// -1 if it doesn't match
+func (m mediaRange) matches(mediaType string) int {
+	mainType, subType := splitMediaType(mediaType)
+	switch {
+	case m.mainType == mainType && m.subType == subType:
+		return 2
+	case m.mainType == mainType && m.subType == "*":
+		return 1
+	case m.mainType == "*" && m.subType == "*":
+		return 0
+	}
+	return -1
+}
+
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(value, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		mainType, subType := splitMediaType(mediaType)
+		ranges = append(ranges, mediaRange{mainType: mainType, subType: subType, quality: quality})
+	}
+	return ranges
+}
+
+func splitMediaType(mediaType string) (string, string) {
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		return strings.ToLower(mediaType[:i]), strings.ToLower(mediaType[i+1:])
+	}
+	return strings.ToLower(mediaType), ""
+}
+
+// EncodeJSON writes v as JSON followed by a newline
+func EncodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,292 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+	Tags    []string `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	hidden  string
+	Skipped string `json:"-" xml:"-"`
+}
+
+type testScoredItem struct {
+	*testItem
+	Score float64 `json:"score"`
+}
+
+func TestRegistry_Negotiate(t *testing.T) {
+	registry := DefaultRegistry()
+	tests := map[string]string{
+		"":                                      MediaTypeJSON,
+		"*/*":                                   MediaTypeJSON,
+		"application/*":                         MediaTypeJSON,
+		"text/csv":                              MediaTypeCSV,
+		"text/*":                                MediaTypeCSV,
+		"text/xml":                              MediaTypeXML,
+		"application/x-msgpack":                 MediaTypeMsgpack,
+		"application/vnd.msgpack":               MediaTypeMsgpack,
+		"application/json;q=0.5, text/csv":      MediaTypeCSV,
+		"text/csv;q=0.5, application/xml;q=0.9": MediaTypeXML,
+		"application/json;q=0, */*":             MediaTypeCSV,
+		"TEXT/CSV; charset=utf-8":               MediaTypeCSV,
+		"text/html, application/xml;q=0.1":      MediaTypeXML,
+	}
+	for accept, expected := range tests {
+		t.Run(fmt.Sprintf("Accept %q", accept), func(t *testing.T) {
+			format, ok := registry.Negotiate(accept)
+
+			assert.True(t, ok)
+			assert.Equal(t, expected, format.MediaType)
+		})
+	}
+
+	for _, accept := range []string{"text/html", "application/json;q=0", "image/*", "nonsense"} {
+		t.Run(fmt.Sprintf("Unsupported %q", accept), func(t *testing.T) {
+			_, ok := registry.Negotiate(accept)
+
+			assert.False(t, ok)
+		})
+	}
+
+	t.Run("Register Replaces", func(t *testing.T) {
+		registry := DefaultRegistry()
+		registry.Register(MediaTypeCSV, "text/csv; header=present", EncoderFunc(EncodeCSV))
+
+		format, _ := registry.Negotiate("text/csv")
+		assert.Equal(t, "text/csv; header=present", format.ContentType)
+		assert.Equal(t, []string{MediaTypeJSON, MediaTypeCSV, MediaTypeXML, MediaTypeMsgpack}, registry.MediaTypes())
+	})
+	t.Run("Problem Content Types", func(t *testing.T) {
+		expected := map[string]string{
+			MediaTypeJSON:    MediaTypeProblemJSON,
+			MediaTypeCSV:     "text/csv; charset=utf-8",
+			MediaTypeXML:     "application/problem+xml; charset=utf-8",
+			MediaTypeMsgpack: MediaTypeMsgpack,
+		}
+		for mediaType, contentType := range expected {
+			format, _ := registry.Negotiate(mediaType)
+			assert.Equal(t, contentType, format.ProblemContentType())
+		}
+		for _, mediaType := range []string{MediaTypeNDJSON, MediaTypeEventStream} {
+			assert.Equal(t, MediaTypeProblemJSON, Format{MediaType: mediaType, ContentType: mediaType}.ProblemContentType())
+		}
+	})
+}
+
+func TestEncodeCSV(t *testing.T) {
+	encode := func(v interface{}) (string, error) {
+		var buffer bytes.Buffer
+		err := EncodeCSV(&buffer, v)
+		return buffer.String(), err
+	}
+
+	t.Run("List", func(t *testing.T) {
+		output, err := encode([]*testItem{{Name: "a, b"}, {Name: "c", Tags: []string{"x", "y"}}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "name,tags\n\"a, b\",\nc,\"[\"\"x\"\",\"\"y\"\"]\"\n", output)
+	})
+	t.Run("Single", func(t *testing.T) {
+		output, err := encode(testItem{Name: "a"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "name,tags\na,\n", output)
+	})
+	t.Run("Embedded", func(t *testing.T) {
+		output, err := encode([]testScoredItem{{testItem: &testItem{Name: "a"}, Score: 0.5}, {Score: 1}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "name,tags,score\na,,0.5\n,,1\n", output)
+	})
+	t.Run("Empty List Has Header", func(t *testing.T) {
+		output, err := encode([]testItem{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "name,tags\n", output)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := encode([]string{"a"})
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestEncodeXML(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, EncodeXML(&buffer, []testItem{{Name: "a", Tags: []string{"x"}}, {Name: "b"}}))
+	assert.Equal(t, xml.Header+"<results><item><name>a</name><tags><tag>x</tag></tags></item><item><name>b</name><tags></tags></item></results>\n", buffer.String())
+
+	buffer.Reset()
+	assert.Nil(t, EncodeXML(&buffer, &testItem{Name: "a"}))
+	assert.Equal(t, xml.Header+"<item><name>a</name><tags></tags></item>\n", buffer.String())
+}
+
+func TestEncodeMsgpack(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{value: nil, expected: []byte{0xc0}},
+		{value: true, expected: []byte{0xc3}},
+		{value: false, expected: []byte{0xc2}},
+		{value: 5, expected: []byte{0x05}},
+		{value: -3, expected: []byte{0xfd}},
+		{value: 200, expected: []byte{0xcc, 0xc8}},
+		{value: 1000, expected: []byte{0xcd, 0x03, 0xe8}},
+		{value: 70000, expected: []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{value: -100, expected: []byte{0xd0, 0x9c}},
+		{value: -1000, expected: []byte{0xd1, 0xfc, 0x18}},
+		{value: uint64(1) << 63, expected: []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{value: 0.5, expected: []byte{0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}},
+		{value: "hi", expected: []byte{0xa2, 'h', 'i'}},
+		{value: strings.Repeat("a", 40), expected: append([]byte{0xd9, 40}, strings.Repeat("a", 40)...)},
+		{value: []int{1, 2}, expected: []byte{0x92, 0x01, 0x02}},
+		{value: map[string]int{"b": 2, "a": 1}, expected: []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{value: testItem{Name: "x"}, expected: []byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0xa1, 'x'}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("Encode %v", test.value), func(t *testing.T) {
+			var buffer bytes.Buffer
+			assert.Nil(t, EncodeMsgpack(&buffer, test.value))
+			assert.Equal(t, test.expected, buffer.Bytes())
+		})
+	}
+
+	t.Run("Long Array", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.Nil(t, EncodeMsgpack(&buffer, make([]bool, 20)))
+		assert.Equal(t, []byte{0xdc, 0x00, 20}, buffer.Bytes()[:3])
+		assert.Len(t, buffer.Bytes(), 23)
+	})
+}
+
+func TestEncodeNDJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, EncodeNDJSON(&buffer, []*testItem{{Name: "a"}, {Name: "b", Tags: []string{"x"}}}))
+	assert.Nil(t, EncodeNDJSON(&buffer, testItem{Name: "c"}))
+
+	assert.Equal(t, "{\"name\":\"a\"}\n{\"name\":\"b\",\"tags\":[\"x\"]}\n{\"name\":\"c\"}\n", buffer.String())
+}
+
+func TestStreamEncoder(t *testing.T) {
+	for _, test := range []struct {
+		mediaType string
+		expected  string
+	}{
+		{MediaTypeNDJSON, "{\"name\":\"a\"}\n{\"name\":\"b\"}\n"},
+		{MediaTypeCSV, "name,tags\na,\nb,\n"},
+	} {
+		t.Run(test.mediaType, func(t *testing.T) {
+			var buffer bytes.Buffer
+			encoder, err := NewStreamEncoder(test.mediaType, &buffer, &testItem{})
+			assert.Nil(t, err)
+
+			assert.Nil(t, encoder.Encode(&testItem{Name: "a"}))
+			assert.Nil(t, encoder.Encode(testItem{Name: "b"}))
+			assert.Nil(t, encoder.Flush())
+			assert.Equal(t, test.expected, buffer.String())
+		})
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := NewStreamEncoder(MediaTypeXML, &bytes.Buffer{}, &testItem{})
+		assert.NotNil(t, err)
+
+		_, err = NewStreamEncoder(MediaTypeCSV, &bytes.Buffer{}, "a")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestStreamDecoder(t *testing.T) {
+	// decode reads every record of input, returning the names decoded and the error of each record
+	decode := func(mediaType, input string) ([]string, []error) {
+		decoder, err := NewStreamDecoder(mediaType, strings.NewReader(input))
+		assert.Nil(t, err)
+
+		var names []string
+		var errs []error
+		for {
+			var item testItem
+			err := decoder.Decode(&item)
+			if err == io.EOF {
+				return names, errs
+			}
+			names = append(names, item.Name)
+			errs = append(errs, err)
+			var recordErr *RecordError
+			if err != nil && !errors.As(err, &recordErr) {
+				return names, errs
+			}
+		}
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		names, errs := decode(MediaTypeNDJSON, "{\"name\":\"a\"}\n\n  {\"name\":\"b\",\"tags\":[\"x\"]}\r\n")
+
+		assert.Equal(t, []string{"a", "b"}, names)
+		assert.Equal(t, []error{nil, nil}, errs)
+	})
+	t.Run("NDJSON Invalid Records", func(t *testing.T) {
+		names, errs := decode(MediaTypeNDJSON, "{\"name\":1}\n{\"nope\":\"a\"}\n{\"name\":\"a\"} {}\n{\"name\":\"b\"}\n")
+
+		assert.Len(t, names, 4)
+		for _, err := range errs[:3] {
+			assert.IsType(t, &RecordError{}, err)
+		}
+		assert.Nil(t, errs[3])
+		assert.Equal(t, "b", names[3])
+	})
+	t.Run("NDJSON Record Too Long", func(t *testing.T) {
+		_, errs := decode(MediaTypeNDJSON, "{\"name\":\""+strings.Repeat("a", MaxRecordSize)+"\"}\n{\"name\":\"b\"}\n")
+
+		assert.Len(t, errs, 1)
+		var recordErr *RecordError
+		assert.False(t, errors.As(errs[0], &recordErr))
+	})
+	t.Run("CSV", func(t *testing.T) {
+		names, errs := decode(MediaTypeCSV, "name\na\n\"b, c\"\n")
+
+		assert.Equal(t, []string{"a", "b, c"}, names)
+		assert.Equal(t, []error{nil, nil}, errs)
+	})
+	t.Run("CSV Invalid Records", func(t *testing.T) {
+		names, errs := decode(MediaTypeCSV, "name\na,b\nc\n")
+
+		assert.Equal(t, []string{"", "c"}, names)
+		assert.IsType(t, &RecordError{}, errs[0])
+		assert.Nil(t, errs[1])
+	})
+	t.Run("CSV Unknown Column", func(t *testing.T) {
+		_, errs := decode(MediaTypeCSV, "name,nope\na,b\n")
+
+		assert.Len(t, errs, 1)
+		assert.IsType(t, &RecordError{}, errs[0])
+	})
+	t.Run("CSV Malformed", func(t *testing.T) {
+		_, errs := decode(MediaTypeCSV, "name\n\"a\n")
+
+		assert.Len(t, errs, 1)
+		var recordErr *RecordError
+		assert.False(t, errors.As(errs[0], &recordErr))
+	})
+	t.Run("Empty", func(t *testing.T) {
+		for _, mediaType := range []string{MediaTypeNDJSON, MediaTypeCSV} {
+			names, errs := decode(mediaType, "")
+			assert.Empty(t, names, mediaType)
+			assert.Empty(t, errs, mediaType)
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := NewStreamDecoder(MediaTypeXML, strings.NewReader(""))
+		assert.NotNil(t, err)
+	})
+}
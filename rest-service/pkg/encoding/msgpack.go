@@ -0,0 +1,148 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// EncodeMsgpack writes v as MessagePack. The value is serialized the same way as JSON, including its field names and
+// custom marshalers, so every format has the same structure. Integers use the smallest encoding that fits, other
+// numbers are written as 64-bit floats and map keys are sorted.
+func EncodeMsgpack(w io.Writer, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := writeMsgpack(buffered, generic); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func writeMsgpack(w *bufio.Writer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if value {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case json.Number:
+		return writeMsgpackNumber(w, value)
+	case string:
+		writeMsgpackHeader(w, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(value)
+		return err
+	case []interface{}:
+		writeMsgpackHeader(w, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeMsgpackHeader(w, len(value), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			if err := writeMsgpack(w, key); err != nil {
+				return err
+			}
+			if err := writeMsgpack(w, value[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack can't encode %T", v)
+}
+
+// writeMsgpackHeader writes the type and length of a string, array or map. Lengths below fixLimit are packed into
+// fixType, otherwise the 8, 16 or 32 bit length type is used. Arrays and maps have no 8 bit type so pass 0.
+func writeMsgpackHeader(w *bufio.Writer, length int, fixType byte, fixLimit int, type8, type16, type32 byte) {
+	switch {
+	case length < fixLimit:
+		_ = w.WriteByte(fixType | byte(length))
+	case type8 != 0 && length <= math.MaxUint8:
+		_ = w.WriteByte(type8)
+		_ = w.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		_ = w.WriteByte(type16)
+		_ = binary.Write(w, binary.BigEndian, uint16(length))
+	default:
+		_ = w.WriteByte(type32)
+		_ = binary.Write(w, binary.BigEndian, uint32(length))
+	}
+}
+
+func writeMsgpackNumber(w *bufio.Writer, number json.Number) error {
+	if integer, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+		writeMsgpackInt(w, integer)
+		return nil
+	}
+	if unsigned, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+		_ = w.WriteByte(0xcf)
+		return binary.Write(w, binary.BigEndian, unsigned)
+	}
+
+	float, err := number.Float64()
+	if err != nil {
+		return err
+	}
+	_ = w.WriteByte(0xcb)
+	return binary.Write(w, binary.BigEndian, math.Float64bits(float))
+}
+
+func writeMsgpackInt(w *bufio.Writer, value int64) {
+	switch {
+	case value >= 0 && value <= math.MaxInt8:
+		_ = w.WriteByte(byte(value))
+	case value < 0 && value >= -32:
+		_ = w.WriteByte(byte(int8(value)))
+	case value >= 0 && value <= math.MaxUint8:
+		_ = w.WriteByte(0xcc)
+		_ = w.WriteByte(byte(value))
+	case value >= 0 && value <= math.MaxUint16:
+		_ = w.WriteByte(0xcd)
+		_ = binary.Write(w, binary.BigEndian, uint16(value))
+	case value >= 0 && value <= math.MaxUint32:
+		_ = w.WriteByte(0xce)
+		_ = binary.Write(w, binary.BigEndian, uint32(value))
+	case value >= 0:
+		_ = w.WriteByte(0xcf)
+		_ = binary.Write(w, binary.BigEndian, uint64(value))
+	case value >= math.MinInt8:
+		_ = w.WriteByte(0xd0)
+		_ = w.WriteByte(byte(int8(value)))
+	case value >= math.MinInt16:
+		_ = w.WriteByte(0xd1)
+		_ = binary.Write(w, binary.BigEndian, int16(value))
+	case value >= math.MinInt32:
+		_ = w.WriteByte(0xd2)
+		_ = binary.Write(w, binary.BigEndian, int32(value))
+	default:
+		_ = w.WriteByte(0xd3)
+		_ = binary.Write(w, binary.BigEndian, value)
+	}
+}
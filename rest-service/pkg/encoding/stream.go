@@ -0,0 +1,222 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// MediaTypeNDJSON is newline delimited JSON, one record per line
+const MediaTypeNDJSON = "application/x-ndjson"
+
+// MediaTypeEventStream is a stream of Server-Sent Events
+const MediaTypeEventStream = "text/event-stream"
+
+// MaxRecordSize is the largest single record a StreamDecoder reads
+const MaxRecordSize = 1 << 20
+
+// StreamEncoder writes records one at a time, so a large list never has to be held in memory
+type StreamEncoder interface {
+	Encode(record interface{}) error
+	// Flush writes any buffered records to the underlying writer
+	Flush() error
+}
+
+// StreamDecoder reads records one at a time, so a large upload never has to be held in memory
+type StreamDecoder interface {
+	// Decode reads the next record into v, returning io.EOF once every record has been read. A *RecordError means only
+	// that record was invalid and decoding can continue, any other error ends the stream.
+	Decode(v interface{}) error
+}
+
+// RecordError is a single invalid record in a stream
+type RecordError struct {
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// NewStreamEncoder creates an encoder of NDJSON or CSV records. CSV records must be structs like sample, whose
+// header row is written straight away so an empty stream still has one.
+func NewStreamEncoder(mediaType string, w io.Writer, sample interface{}) (StreamEncoder, error) {
+	switch mediaType {
+	case MediaTypeNDJSON:
+		return &ndjsonEncoder{writer: bufio.NewWriter(w)}, nil
+	case MediaTypeCSV:
+		return newCSVEncoder(w, sample)
+	}
+	return nil, fmt.Errorf("unable to stream %s", mediaType)
+}
+
+// NewStreamDecoder creates a decoder of NDJSON or CSV records. CSV must start with a header row naming the JSON field
+// each column is decoded into.
+func NewStreamDecoder(mediaType string, r io.Reader) (StreamDecoder, error) {
+	switch mediaType {
+	case MediaTypeNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), MaxRecordSize)
+		return &ndjsonDecoder{scanner: scanner}, nil
+	case MediaTypeCSV:
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		return &csvDecoder{reader: reader}, nil
+	}
+	return nil, fmt.Errorf("unable to stream %s", mediaType)
+}
+
+// EncodeNDJSON writes each element of a slice as a line of JSON, or any other value as a single line
+func EncodeNDJSON(w io.Writer, v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	encoder := json.NewEncoder(w)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return encoder.Encode(v)
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ndjsonEncoder struct {
+	writer *bufio.Writer
+}
+
+func (e *ndjsonEncoder) Encode(record interface{}) error {
+	// json.Encoder terminates every value with a newline and never writes one inside it
+	return json.NewEncoder(e.writer).Encode(record)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.writer.Flush()
+}
+
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *ndjsonDecoder) Decode(v interface{}) error {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return &RecordError{Err: err}
+		}
+		if decoder.More() {
+			return &RecordError{Err: errors.New("only a single object is allowed per line")}
+		}
+		return nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("a line is longer than %d bytes, %w", MaxRecordSize, err)
+		}
+		return err
+	}
+	return io.EOF
+}
+
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []csvColumn
+}
+
+func newCSVEncoder(w io.Writer, sample interface{}) (*csvEncoder, error) {
+	recordType := reflect.TypeOf(sample)
+	for recordType != nil && recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	if recordType == nil || recordType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv can only stream structs, not %v", recordType)
+	}
+
+	encoder := &csvEncoder{writer: csv.NewWriter(w), columns: csvColumns(recordType, nil)}
+	header := make([]string, 0, len(encoder.columns))
+	for _, column := range encoder.columns {
+		header = append(header, column.name)
+	}
+	return encoder, encoder.writer.Write(header)
+}
+
+func (e *csvEncoder) Encode(record interface{}) error {
+	row := reflect.ValueOf(record)
+	cells := make([]string, 0, len(e.columns))
+	for _, column := range e.columns {
+		cell, err := csvCell(row, column.index)
+		if err != nil {
+			return fmt.Errorf("error encoding csv column %s, %w", column.name, err)
+		}
+		cells = append(cells, cell)
+	}
+	return e.writer.Write(cells)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	header []string
+}
+
+// Decode reads the next row into v. Cells are decoded as JSON strings of the field named by their column, so unknown
+// columns and fields that aren't strings are rejected.
+func (d *csvDecoder) Decode(v interface{}) error {
+	if d.header == nil {
+		header, err := d.reader.Read()
+		if err == io.EOF {
+			return io.EOF
+		} else if err != nil {
+			return fmt.Errorf("error reading csv header, %w", err)
+		}
+		d.header = append([]string(nil), header...)
+	}
+
+	row, err := d.reader.Read()
+	if errors.Is(err, csv.ErrFieldCount) {
+		return &RecordError{Err: fmt.Errorf("must have %d columns", len(d.header))}
+	} else if err != nil {
+		return err
+	}
+
+	fields := make(map[string]string, len(d.header))
+	for i, name := range d.header {
+		if len(row[i]) > 0 {
+			fields[name] = row[i]
+		}
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return &RecordError{Err: err}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &RecordError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package encoding
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+)
+
+// xmlListElement is the root element of a list, whose items are written as its children
+const xmlListElement = "results"
+
+// EncodeXML writes v as an XML document. Lists are wrapped in a <results> element as XML needs a single root.
+func EncodeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: xmlListElement}}
+	if err := encoder.EncodeToken(root); err != nil {
+		return err
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	if err := encoder.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
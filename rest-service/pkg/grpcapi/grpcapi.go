@@ -0,0 +1,280 @@
+// Package grpcapi serves people over gRPC, sharing the store, authentication, scopes and audit log with the REST API.
+package grpcapi
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/api"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/peoplepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"net/http"
+)
+
+// Server implements peoplepb.PeopleServiceServer over a PersonStore
+type Server struct {
+	peoplepb.UnimplementedPeopleServiceServer
+
+	store models.PersonStore
+	// authenticator is nil when authentication is disabled
+	authenticator *auth.Authenticator
+	// auditLog is nil when auditing is disabled
+	auditLog   *audit.Log
+	rateLimits api.RateLimitConfig
+	limiters   rateLimiters
+}
+
+// Option configures optional behaviour of the Server
+type Option func(s *Server)
+
+// WithAuthenticator requires every call to carry credentials granting the people:read scope, in the x-api-key or
+// authorization metadata
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(s *Server) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithAuditLog records every read of people to auditLog, calls that can't be recorded fail with codes.Internal
+func WithAuditLog(auditLog *audit.Log) Option {
+	return func(s *Server) {
+		s.auditLog = auditLog
+	}
+}
+
+// New creates a Server serving the people in store
+func New(store models.PersonStore, options ...Option) *Server {
+	s := &Server{store: store}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// GRPCServer creates a grpc.Server with the people service registered behind the authentication and rate limiting
+// interceptors
+func (s *Server) GRPCServer(options ...grpc.ServerOption) *grpc.Server {
+	options = append(options, grpc.ChainUnaryInterceptor(s.authenticateUnary, s.rateLimitUnary),
+		grpc.ChainStreamInterceptor(s.authenticateStream, s.rateLimitStream))
+	server := grpc.NewServer(options...)
+	peoplepb.RegisterPeopleServiceServer(server, s)
+	return server
+}
+
+func (s *Server) Get(ctx context.Context, request *peoplepb.GetPersonRequest) (*peoplepb.Person, error) {
+	id, err := uuid.FromString(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid ID provided")
+	}
+
+	person, err := s.store.FindByID(id)
+	if errors.Is(err, models.ErrPersonNotFound) {
+		return nil, status.Error(codes.NotFound, "Person with the provided ID was not found.")
+	} else if err != nil {
+		log.Printf("Error finding person %s, %s\n", id.String(), err.Error())
+		return nil, status.Error(codes.Internal, "Unable to find person.")
+	}
+
+	if err := s.audit(ctx, audit.ActionRead, []*models.Person{person}); err != nil {
+		return nil, err
+	}
+	return s.toProto(ctx, person), nil
+}
+
+func (s *Server) Search(ctx context.Context, request *peoplepb.SearchPeopleRequest) (*peoplepb.SearchPeopleResponse, error) {
+	query := models.Query{Filters: make([]models.Filter, 0, 3)}
+	for _, field := range []struct{ name, value string }{
+		{models.FieldFirstName, request.GetFirstName()},
+		{models.FieldLastName, request.GetLastName()},
+		{models.FieldPhoneNumber, request.GetPhoneNumber()},
+	} {
+		if len(field.value) == 0 {
+			continue
+		}
+		filter, err := models.NewFilter(field.name, field.value, request.GetIgnoreCase())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid search parameters provided, %s", err.Error())
+		}
+		query.Filters = append(query.Filters, filter)
+	}
+
+	if len(request.GetPhoneNumber()) > 0 && !s.hasScope(ctx, api.ScopePII) {
+		return nil, status.Errorf(codes.PermissionDenied, "The %s scope is required to search by phone_number", api.ScopePII)
+	}
+
+	people, err := s.store.Search(query)
+	if err != nil {
+		log.Printf("Error searching people, %s\n", err.Error())
+		return nil, status.Error(codes.Internal, "Unable to search people.")
+	}
+
+	if err := s.audit(ctx, audit.ActionSearch, people); err != nil {
+		return nil, err
+	}
+	response := &peoplepb.SearchPeopleResponse{People: make([]*peoplepb.Person, 0, len(people))}
+	for _, person := range people {
+		response.People = append(response.People, s.toProto(ctx, person))
+	}
+	return response, nil
+}
+
+func (s *Server) List(_ *peoplepb.ListPeopleRequest, stream peoplepb.PeopleService_ListServer) error {
+	people, err := s.store.All()
+	if err != nil {
+		log.Printf("Error listing people, %s\n", err.Error())
+		return status.Error(codes.Internal, "Unable to list people.")
+	}
+
+	if err := s.audit(stream.Context(), audit.ActionSearch, people); err != nil {
+		return err
+	}
+	for _, person := range people {
+		if err := stream.Send(s.toProto(stream.Context(), person)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toProto converts person to its protobuf message, masking the phone number unless the caller has the PII scope
+func (s *Server) toProto(ctx context.Context, person *models.Person) *peoplepb.Person {
+	phoneNumber := person.PhoneNumber
+	if !s.hasScope(ctx, api.ScopePII) {
+		phoneNumber = models.MaskPhoneNumber(phoneNumber)
+	}
+	return &peoplepb.Person{
+		Id:          person.ID.String(),
+		FirstName:   person.FirstName,
+		LastName:    person.LastName,
+		PhoneNumber: phoneNumber,
+	}
+}
+
+// authenticate adds the caller's principal to ctx, failing unless they have the read scope. Every call is allowed
+// when authentication is disabled. Callers that have failed to authenticate too often are rejected whatever their
+// credentials.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+	failures := s.authFailureLimiter()
+	client := peerKey(ctx)
+	if result := failures.Peek(client); !result.Allowed {
+		return ctx, rateLimited("Too many failed authentication attempts", result)
+	}
+
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	principal, err := s.authenticator.AuthenticateHeader(header)
+	if err != nil && !errors.Is(err, auth.ErrMissingCredentials) {
+		failures.Allow(client)
+	}
+	if errors.Is(err, auth.ErrDisabled) {
+		return ctx, status.Error(codes.PermissionDenied, "The provided credentials have been disabled")
+	} else if errors.Is(err, auth.ErrMissingCredentials) {
+		return ctx, status.Error(codes.Unauthenticated, "Authentication required, provide x-api-key or bearer token authorization metadata")
+	} else if err != nil {
+		log.Printf("Error authenticating gRPC call, %s\n", err.Error())
+		return ctx, status.Error(codes.Unauthenticated, "Invalid credentials provided")
+	}
+
+	if !principal.HasScope(api.ScopeRead) {
+		return ctx, status.Errorf(codes.PermissionDenied, "The %s scope is required", api.ScopeRead)
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, request interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+func (s *Server) authenticateStream(server interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(server, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream replaces the context of a stream with one carrying the principal
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// hasScope reports whether the caller was granted scope, always true when authentication is disabled
+func (s *Server) hasScope(ctx context.Context, scope string) bool {
+	if s.authenticator == nil {
+		return true
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	return ok && principal.HasScope(scope)
+}
+
+// audit records that the caller performed action on people, it must be called before they are masked. Returns the
+// status to fail the call with if it couldn't be recorded, so nothing is sent that wasn't audited.
+func (s *Server) audit(ctx context.Context, action string, people []*models.Person) error {
+	if s.auditLog == nil {
+		return nil
+	}
+
+	entry := audit.Entry{Actor: "anonymous", Action: action, Resources: make([]string, 0, len(people))}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		entry.Actor = principal.Method + ":" + principal.Subject
+	} else if subject, ok := clientSubject(ctx); ok {
+		entry.Actor = "tls:" + subject.String()
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		entry.RemoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(api.RequestIDHeader)) > 0 {
+		entry.RequestID = md.Get(api.RequestIDHeader)[0]
+	}
+	for _, person := range people {
+		entry.Resources = append(entry.Resources, person.ID.String())
+	}
+
+	if err := s.auditLog.Record(entry); err != nil {
+		log.Printf("Error recording %s of %v in audit log, %s\n", action, entry.Resources, err.Error())
+		return status.Error(codes.Internal, "Unable to record the call in the audit log.")
+	}
+	return nil
+}
+
+// clientSubject returns the subject of the certificate the caller was verified with, false if the call wasn't made over
+// TLS with a verified client certificate
+func clientSubject(ctx context.Context) (pkix.Name, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return pkix.Name{}, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return pkix.Name{}, false
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject, true
+}
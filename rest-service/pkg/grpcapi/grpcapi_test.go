@@ -0,0 +1,313 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/api"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/audit"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/peoplepb"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dial serves server on an in-process listener and returns a client connected to it
+func dial(t *testing.T, server *Server) peoplepb.PeopleServiceClient {
+	return dialWith(t, server, insecure.NewCredentials())
+}
+
+// dialWith serves server on an in-process listener with options and returns a client connected to it with creds
+func dialWith(t *testing.T, server *Server, creds credentials.TransportCredentials, options ...grpc.ServerOption) peoplepb.PeopleServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := server.GRPCServer(options...)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(creds))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return peoplepb.NewPeopleServiceClient(conn)
+}
+
+// newTestCertificate generates a certificate for localhost named commonName signed by parent, or a self-signed CA if
+// parent is nil
+func newTestCertificate(t *testing.T, commonName string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"StackPath"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestServer(t *testing.T) {
+	client := dial(t, New(models.NewMemoryStore(models.SamplePeople()...)))
+	ctx := context.Background()
+
+	t.Run("Get", func(t *testing.T) {
+		person, err := client.Get(ctx, &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Brian", person.GetFirstName())
+		assert.Equal(t, "Smith", person.GetLastName())
+		assert.Equal(t, "+44 7700 900077", person.GetPhoneNumber())
+	})
+	t.Run("Get Not Found", func(t *testing.T) {
+		_, err := client.Get(ctx, &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e71"})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+	t.Run("Get Invalid ID", func(t *testing.T) {
+		_, err := client.Get(ctx, &peoplepb.GetPersonRequest{Id: "this-is-not-a-uuid"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+	t.Run("Search", func(t *testing.T) {
+		response, err := client.Search(ctx, &peoplepb.SearchPeopleRequest{LastName: "smi*", IgnoreCase: true})
+
+		assert.Nil(t, err)
+		assert.Len(t, response.GetPeople(), 2)
+		for _, person := range response.GetPeople() {
+			assert.Equal(t, "Smith", person.GetLastName())
+		}
+	})
+	t.Run("Search Phone Number", func(t *testing.T) {
+		response, err := client.Search(ctx, &peoplepb.SearchPeopleRequest{PhoneNumber: "+44 7700 900077"})
+
+		assert.Nil(t, err)
+		assert.Len(t, response.GetPeople(), 2)
+	})
+	t.Run("List Streams Every Person", func(t *testing.T) {
+		stream, err := client.List(ctx, &peoplepb.ListPeopleRequest{})
+		assert.Nil(t, err)
+
+		ids := make([]string, 0)
+		for {
+			person, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			ids = append(ids, person.GetId())
+		}
+
+		expected := make([]string, 0)
+		for _, person := range models.AllPeople() {
+			expected = append(expected, person.ID.String())
+		}
+		assert.Equal(t, expected, ids)
+	})
+}
+
+func TestServer_Authentication(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{api.ScopeRead}},
+		{Name: "pii", Key: "pii-key", Scopes: []string{api.ScopeRead, api.ScopePII}},
+		{Name: "writer", Key: "writer-key", Scopes: []string{api.ScopeWrite}},
+	}})
+	assert.Nil(t, err)
+	client := dial(t, New(models.NewMemoryStore(models.SamplePeople()...), WithAuthenticator(authenticator)))
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+	request := &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"}
+
+	t.Run("Missing Credentials", func(t *testing.T) {
+		_, err := client.Get(context.Background(), request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		stream, err := client.List(context.Background(), &peoplepb.ListPeopleRequest{})
+		assert.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("Invalid Credentials", func(t *testing.T) {
+		_, err := client.Get(withKey("wrong"), request)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("Missing Scope", func(t *testing.T) {
+		_, err := client.Get(withKey("writer-key"), request)
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+	t.Run("Masked Without PII Scope", func(t *testing.T) {
+		person, err := client.Get(withKey("reader-key"), request)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "+44 7700 ****77", person.GetPhoneNumber())
+
+		_, err = client.Search(withKey("reader-key"), &peoplepb.SearchPeopleRequest{PhoneNumber: "+44 7700 900077"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+	t.Run("Unmasked With PII Scope", func(t *testing.T) {
+		stream, err := client.List(withKey("pii-key"), &peoplepb.ListPeopleRequest{})
+		assert.Nil(t, err)
+
+		person, err := stream.Recv()
+		assert.Nil(t, err)
+		assert.Equal(t, models.AllPeople()[0].PhoneNumber, person.GetPhoneNumber())
+	})
+}
+
+func TestServer_AuditFailure(t *testing.T) {
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	assert.Nil(t, err)
+	// Every entry fails to be recorded once the file is closed
+	assert.Nil(t, auditLog.Close())
+	client := dial(t, New(models.NewMemoryStore(models.SamplePeople()...), WithAuditLog(auditLog)))
+	ctx := context.Background()
+
+	_, err = client.Get(ctx, &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = client.Search(ctx, &peoplepb.SearchPeopleRequest{LastName: "Smith"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	stream, err := client.List(ctx, &peoplepb.ListPeopleRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	search := "/stackpath.people.v1.PeopleService/Search"
+	client := dial(t, New(models.NewMemoryStore(models.SamplePeople()...), WithRateLimits(api.RateLimitConfig{
+		Default: ratelimit.Limit{Rate: 0.001, Burst: 2},
+		Routes:  map[string]ratelimit.Limit{search: {Rate: 0.001, Burst: 1}},
+	})))
+	ctx := context.Background()
+	request := &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"}
+
+	t.Run("Default", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := client.Get(ctx, request)
+			assert.Nil(t, err)
+		}
+		_, err := client.Get(ctx, request)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		// Each method has its own allowance
+		stream, err := client.List(ctx, &peoplepb.ListPeopleRequest{})
+		assert.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Nil(t, err)
+	})
+	t.Run("Method", func(t *testing.T) {
+		_, err := client.Search(ctx, &peoplepb.SearchPeopleRequest{LastName: "Smith"})
+		assert.Nil(t, err)
+		_, err = client.Search(ctx, &peoplepb.SearchPeopleRequest{LastName: "Smith"})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func TestServer_AuthFailureRateLimit(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Name: "reader", Key: "reader-key", Scopes: []string{api.ScopeRead}}}})
+	assert.Nil(t, err)
+	client := dial(t, New(models.NewMemoryStore(models.SamplePeople()...), WithAuthenticator(authenticator),
+		WithRateLimits(api.RateLimitConfig{AuthFailures: ratelimit.Limit{Rate: 0.001, Burst: 3}})))
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+	request := &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"}
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(withKey("wrong"), request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	_, err = client.Get(withKey("wrong"), request)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// Valid credentials are rejected too so keys can't be confirmed once limited
+	_, err = client.Get(withKey("reader-key"), request)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestServer_TLS(t *testing.T) {
+	serverCA := newTestCertificate(t, "server-ca", nil)
+	serverCertificate := newTestCertificate(t, "localhost", &serverCA)
+	clientCA := newTestCertificate(t, "clients", nil)
+	client := newTestCertificate(t, "billing", &clientCA)
+	roots, clientCAs := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(serverCA.Leaf)
+	clientCAs.AddCert(clientCA.Leaf)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	assert.Nil(t, err)
+	serverTLS := grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}))
+	server := New(models.NewMemoryStore(models.SamplePeople()...), WithAuditLog(auditLog))
+	request := &peoplepb.GetPersonRequest{Id: "df12ce76-767b-4bf0-bccb-816745df9e70"}
+
+	t.Run("Verified Client", func(t *testing.T) {
+		client := dialWith(t, server, credentials.NewTLS(&tls.Config{ServerName: "localhost", RootCAs: roots, Certificates: []tls.Certificate{client}}), serverTLS)
+
+		person, err := client.Get(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, "Brian", person.GetFirstName())
+
+		// The client certificate identifies the caller in the audit log
+		assert.Nil(t, auditLog.Close())
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		var entry audit.Entry
+		assert.Nil(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, "tls:CN=billing,O=StackPath", entry.Actor)
+	})
+	t.Run("No Client Certificate", func(t *testing.T) {
+		client := dialWith(t, server, credentials.NewTLS(&tls.Config{ServerName: "localhost", RootCAs: roots}), serverTLS)
+
+		_, err := client.Get(context.Background(), request)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+	t.Run("Plaintext Refused", func(t *testing.T) {
+		client := dialWith(t, server, insecure.NewCredentials(), serverTLS)
+
+		_, err := client.Get(context.Background(), request)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
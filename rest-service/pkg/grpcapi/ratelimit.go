@@ -0,0 +1,111 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/api"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/auth"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"sync"
+)
+
+// WithRateLimits limits how often each client may make each call, with the same settings as the REST API. Calls are
+// looked up in config.Routes by their full method name, e.g. /stackpath.people.v1.PeopleService/Search, and otherwise
+// get the default. Clients are identified by their authenticated principal or otherwise their address.
+func WithRateLimits(config api.RateLimitConfig) Option {
+	return func(s *Server) {
+		s.rateLimits = config
+	}
+}
+
+// rateLimiters holds the limiter of each method
+type rateLimiters struct {
+	mu           sync.Mutex
+	limiters     map[string]*ratelimit.Limiter
+	authFailures *ratelimit.Limiter
+}
+
+// limiter returns the method's limiter, creating it on first use
+func (s *Server) limiter(method string) *ratelimit.Limiter {
+	s.limiters.mu.Lock()
+	defer s.limiters.mu.Unlock()
+
+	if limiter, ok := s.limiters.limiters[method]; ok {
+		return limiter
+	}
+	limit, ok := s.rateLimits.Routes[method]
+	if !ok {
+		limit = s.rateLimits.Default
+	}
+	if s.limiters.limiters == nil {
+		s.limiters.limiters = make(map[string]*ratelimit.Limiter)
+	}
+	s.limiters.limiters[method] = ratelimit.New(limit)
+	return s.limiters.limiters[method]
+}
+
+// authFailureLimiter returns the limiter of failed authentication attempts, creating it on first use
+func (s *Server) authFailureLimiter() *ratelimit.Limiter {
+	s.limiters.mu.Lock()
+	defer s.limiters.mu.Unlock()
+
+	if s.limiters.authFailures == nil {
+		s.limiters.authFailures = ratelimit.New(s.rateLimits.AuthFailures)
+	}
+	return s.limiters.authFailures
+}
+
+// rateLimit fails with codes.ResourceExhausted if the caller has used up their allowance for method
+func (s *Server) rateLimit(ctx context.Context, method string) error {
+	result := s.limiter(method).Allow(rateLimitKey(ctx))
+	if !result.Allowed {
+		return rateLimited("Too many requests", result)
+	}
+	return nil
+}
+
+func (s *Server) rateLimitUnary(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.rateLimit(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+func (s *Server) rateLimitStream(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.rateLimit(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(server, stream)
+}
+
+// rateLimited returns the status of a call rejected by a limiter
+func rateLimited(reason string, result ratelimit.Result) error {
+	return status.Error(codes.ResourceExhausted, fmt.Sprintf("%s, retry in %d seconds", reason, int(math.Ceil(result.RetryAfter.Seconds()))))
+}
+
+// rateLimitKey identifies the caller, by principal if authenticated otherwise by address
+func rateLimitKey(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return "principal:" + principal.Method + ":" + principal.Subject
+	}
+	return peerKey(ctx)
+}
+
+// peerKey identifies the caller by the host it connected from
+func peerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "addr:"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "addr:" + host
+}
@@ -0,0 +1,195 @@
+// Package health implements liveness and readiness endpoints backed by pluggable checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single check may take before it is reported as failed
+const DefaultTimeout = 2 * time.Second
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports a problem by returning an error. Checks should respect ctx cancellation.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report is the aggregated outcome of every check, Status is only ok if every check passed
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker holds the registered liveness and readiness checks. All functions are safe to call from multiple
+// goroutines.
+type Checker struct {
+	// Timeout bounds each check, DefaultTimeout if zero
+	Timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+func New() *Checker {
+	return &Checker{Timeout: DefaultTimeout}
+}
+
+// AddLivenessCheck registers a check that must pass for the process to be considered alive. Failing liveness usually
+// gets the instance restarted, so only register checks that a restart would fix.
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers a check that must pass for the instance to receive traffic
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name: name, check: check})
+}
+
+// Liveness runs every liveness check
+func (c *Checker) Liveness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.liveness...)
+	c.mu.RUnlock()
+	return c.run(ctx, checks)
+}
+
+// Readiness runs every readiness check, the instance is also not ready if it isn't alive
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append(append([]namedCheck(nil), c.liveness...), c.readiness...)
+	c.mu.RUnlock()
+	return c.run(ctx, checks)
+}
+
+// run executes the checks concurrently, each with its own timeout
+func (c *Checker) run(ctx context.Context, checks []namedCheck) Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := Report{Status: StatusOK, Checks: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, check, timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check namedCheck, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked, %v", r)
+			}
+		}()
+		done <- check.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out, %w", ctx.Err())
+	}
+
+	result := CheckResult{Name: check.name, Status: StatusOK, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report, 200 OK if alive otherwise 503 Service Unavailable
+func (c *Checker) LivenessHandler() http.Handler {
+	return reportHandler(c.Liveness)
+}
+
+// ReadinessHandler serves the readiness report, 200 OK if ready otherwise 503 Service Unavailable
+func (c *Checker) ReadinessHandler() http.Handler {
+	return reportHandler(c.Readiness)
+}
+
+func reportHandler(run func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("Error writting health report %s\n", err.Error())
+		}
+	})
+}
+
+// ErrShuttingDown is reported by a ShutdownFlag check once shutdown has started
+var ErrShuttingDown = errors.New("shutting down")
+
+// ShutdownFlag is a readiness check that fails once Set is called, so load balancers stop routing new requests to an
+// instance that is draining
+type ShutdownFlag struct {
+	shuttingDown int32
+}
+
+// Set marks the instance as shutting down
+func (f *ShutdownFlag) Set() {
+	atomic.StoreInt32(&f.shuttingDown, 1)
+}
+
+// Check fails with ErrShuttingDown once Set has been called
+func (f *ShutdownFlag) Check(context.Context) error {
+	if atomic.LoadInt32(&f.shuttingDown) == 1 {
+		return ErrShuttingDown
+	}
+	return nil
+}
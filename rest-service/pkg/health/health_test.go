@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	t.Run("No Checks", func(t *testing.T) {
+		report := New().Readiness(context.Background())
+
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Empty(t, report.Checks)
+	})
+	t.Run("Readiness Includes Liveness", func(t *testing.T) {
+		checker := New()
+		checker.AddLivenessCheck("alive", func(context.Context) error { return nil })
+		checker.AddReadinessCheck("ready", func(context.Context) error { return nil })
+
+		assert.Len(t, checker.Liveness(context.Background()).Checks, 1)
+		report := checker.Readiness(context.Background())
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Equal(t, "alive", report.Checks[0].Name)
+		assert.Equal(t, "ready", report.Checks[1].Name)
+	})
+	t.Run("Failing Check", func(t *testing.T) {
+		checker := New()
+		checker.AddReadinessCheck("ok", func(context.Context) error { return nil })
+		checker.AddReadinessCheck("broken", func(context.Context) error { return errors.New("database unreachable") })
+
+		report := checker.Readiness(context.Background())
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, StatusOK, report.Checks[0].Status)
+		assert.Equal(t, StatusFail, report.Checks[1].Status)
+		assert.Equal(t, "database unreachable", report.Checks[1].Error)
+		assert.Equal(t, StatusOK, checker.Liveness(context.Background()).Status)
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		checker := New()
+		checker.Timeout = 20 * time.Millisecond
+		release := make(chan struct{})
+		defer close(release)
+		checker.AddReadinessCheck("slow", func(context.Context) error {
+			<-release
+			return nil
+		})
+
+		begin := time.Now()
+		report := checker.Readiness(context.Background())
+		assert.True(t, time.Since(begin) < time.Second)
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Contains(t, report.Checks[0].Error, "timed out")
+	})
+	t.Run("Panic", func(t *testing.T) {
+		checker := New()
+		checker.AddLivenessCheck("panics", func(context.Context) error { panic("boom") })
+
+		report := checker.Liveness(context.Background())
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Contains(t, report.Checks[0].Error, "boom")
+	})
+}
+
+func TestChecker_Handlers(t *testing.T) {
+	checker := New()
+	shutdown := &ShutdownFlag{}
+	checker.AddReadinessCheck("shutdown", shutdown.Check)
+
+	serve := func(handler http.Handler) (*httptest.ResponseRecorder, Report) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report Report
+		_ = json.NewDecoder(w.Result().Body).Decode(&report)
+		_ = w.Result().Body.Close()
+		return w, report
+	}
+
+	t.Run("Ready", func(t *testing.T) {
+		w, report := serve(checker.ReadinessHandler())
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Equal(t, StatusOK, report.Status)
+	})
+	t.Run("Shutting Down", func(t *testing.T) {
+		shutdown.Set()
+		w, report := serve(checker.ReadinessHandler())
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, ErrShuttingDown.Error(), report.Checks[0].Error)
+
+		w, report = serve(checker.LivenessHandler())
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusOK, report.Status)
+	})
+}
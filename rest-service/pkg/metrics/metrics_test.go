@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("requests_total", "Total requests.", "route", "status")
+	inFlight := registry.NewGauge("in_flight", "In flight requests.")
+	registry.NewGaugeFunc("size", "Size with a \\ and\nnewline.", func() float64 { return 42 })
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "200")
+	requests.Add(2.5, "/a", "200")
+	requests.Add(-1, "/a", "200")
+	requests.Inc("/a", "quote\"d")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	var output bytes.Buffer
+	assert.Nil(t, registry.WriteText(&output))
+	assert.Equal(t, `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{route="/a",status="200"} 3.5
+requests_total{route="/a",status="quote\"d"} 1
+requests_total{route="/b",status="200"} 1
+# HELP in_flight In flight requests.
+# TYPE in_flight gauge
+in_flight 1
+# HELP size Size with a \\ and\nnewline.
+# TYPE size gauge
+size 42
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+`, output.String())
+
+	t.Run("Handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, output.String(), w.Body.String())
+	})
+	t.Run("Wrong Label Count", func(t *testing.T) {
+		assert.Panics(t, func() { requests.Inc("/a") })
+	})
+}
@@ -1,8 +1,103 @@
 package models
 
-import "time"
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+)
 
+// ErrorCode is a stable, machine readable identifier of the kind of problem an error describes. Unlike the detail
+// message, codes never change so clients can rely on them.
+type ErrorCode string
+
+const (
+	ProblemInvalidID              ErrorCode = "invalid_id"
+	ProblemInvalidBody            ErrorCode = "invalid_body"
+	ProblemInvalidPaging          ErrorCode = "invalid_paging"
+	ProblemInvalidSearch          ErrorCode = "invalid_search"
+	ProblemValidationFailed       ErrorCode = "validation_failed"
+	ProblemIDNotAllowed           ErrorCode = "id_not_allowed"
+	ProblemIDMismatch             ErrorCode = "id_mismatch"
+	ProblemPersonExists           ErrorCode = "person_exists"
+	ProblemInvalidParameter       ErrorCode = "invalid_parameter"
+	ProblemUnsupportedMediaType   ErrorCode = "unsupported_media_type"
+	ProblemEventExpired           ErrorCode = "event_expired"
+	ProblemPersonNotFound         ErrorCode = "person_not_found"
+	ProblemPreconditionFailed     ErrorCode = "precondition_failed"
+	ProblemAuthenticationRequired ErrorCode = "authentication_required"
+	ProblemInvalidCredentials     ErrorCode = "invalid_credentials"
+	ProblemCredentialsDisabled    ErrorCode = "credentials_disabled"
+	ProblemInsufficientScope      ErrorCode = "insufficient_scope"
+	ProblemNotAcceptable          ErrorCode = "not_acceptable"
+	ProblemRateLimited            ErrorCode = "rate_limited"
+	ProblemNotFound               ErrorCode = "not_found"
+	ProblemMethodNotAllowed       ErrorCode = "method_not_allowed"
+	ProblemInternal               ErrorCode = "internal_error"
+)
+
+var errorTitles = map[ErrorCode]string{
+	ProblemInvalidID:              "Invalid ID",
+	ProblemInvalidBody:            "Invalid request body",
+	ProblemInvalidPaging:          "Invalid paging parameters",
+	ProblemInvalidSearch:          "Invalid search parameters",
+	ProblemValidationFailed:       "Invalid person",
+	ProblemIDNotAllowed:           "ID not allowed",
+	ProblemIDMismatch:             "ID mismatch",
+	ProblemPersonExists:           "Person already exists",
+	ProblemInvalidParameter:       "Invalid parameter",
+	ProblemUnsupportedMediaType:   "Unsupported media type",
+	ProblemEventExpired:           "Event no longer available",
+	ProblemPersonNotFound:         "Person not found",
+	ProblemPreconditionFailed:     "Precondition failed",
+	ProblemAuthenticationRequired: "Authentication required",
+	ProblemInvalidCredentials:     "Invalid credentials",
+	ProblemCredentialsDisabled:    "Credentials disabled",
+	ProblemInsufficientScope:      "Insufficient scope",
+	ProblemNotAcceptable:          "Not acceptable",
+	ProblemRateLimited:            "Too many requests",
+	ProblemNotFound:               "Not found",
+	ProblemMethodNotAllowed:       "Method not allowed",
+	ProblemInternal:               "Internal server error",
+}
+
+// ErrorCodes returns every error code in alphabetical order
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0, len(errorTitles))
+	for code := range errorTitles {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Title returns the short, human readable summary shared by every error with the code
+func (c ErrorCode) Title() string {
+	if title, ok := errorTitles[c]; ok {
+		return title
+	}
+	return string(c)
+}
+
+// Error is an RFC 7807 problem details object. Code, Timestamp, RequestID and Details are extension members.
 type Error struct {
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+	XMLName xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	// Type is a URI reference identifying the kind of problem, one per Code
+	Type   string `json:"type" xml:"type"`
+	Title  string `json:"title" xml:"title"`
+	Status int    `json:"status" xml:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail" xml:"detail"`
+	// Instance is the path of the request that failed
+	Instance  string        `json:"instance,omitempty" xml:"instance,omitempty"`
+	Code      ErrorCode     `json:"code" xml:"code"`
+	Timestamp time.Time     `json:"timestamp" xml:"timestamp"`
+	RequestID string        `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	Details   []ErrorDetail `json:"details,omitempty" xml:"details>detail,omitempty"`
+}
+
+// ErrorDetail describes a problem with a single field of a request
+type ErrorDetail struct {
+	Field   string `json:"field" xml:"field"`
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
 }
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// EventType is the kind of change an Event records
+type EventType string
+
+const (
+	EventPersonCreated EventType = "person.created"
+	EventPersonUpdated EventType = "person.updated"
+	EventPersonDeleted EventType = "person.deleted"
+)
+
+// EventTypes returns every type of event
+func EventTypes() []EventType {
+	return []EventType{EventPersonCreated, EventPersonUpdated, EventPersonDeleted}
+}
+
+// DefaultEventHistory is how many events an EventBus keeps for subscribers to resume from by default
+const DefaultEventHistory = 1000
+
+// subscriberBuffer is how many events a subscriber can fall behind by before it is dropped
+const subscriberBuffer = 64
+
+// ErrEventExpired is returned by EventBus.Subscribe when resuming after an event that is no longer in the history
+var ErrEventExpired = errors.New("event is no longer available")
+
+// Event is a change to a person
+type Event struct {
+	// ID increases by one with every event published to a bus, starting from 1. Buses start again from 1 every time
+	// the service starts so IDs are only unique within an EventBus.Epoch, see EventBus.EventID.
+	ID       uint64    `json:"id" xml:"id"`
+	Type     EventType `json:"type" xml:"type"`
+	Time     time.Time `json:"time" xml:"time"`
+	PersonID uuid.UUID `json:"person_id" xml:"person_id"`
+	// Person is the person after the change, nil when they were deleted
+	Person *Person `json:"person" xml:"person"`
+}
+
+// EventBus publishes events to every subscriber, keeping the most recent ones so subscribers can resume after
+// disconnecting without missing any
+type EventBus struct {
+	// epoch identifies this bus, events with the same ID from different buses are different events
+	epoch uuid.UUID
+	mu    sync.Mutex
+	// history holds the retained events oldest first, at most maxHistory of them
+	history     []Event
+	maxHistory  int
+	lastID      uint64
+	subscribers map[*Subscription]struct{}
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewEventBus creates an EventBus retaining the last history events
+func NewEventBus(history int) *EventBus {
+	return &EventBus{epoch: uuid.NewV4(), maxHistory: history, subscribers: make(map[*Subscription]struct{}), now: time.Now}
+}
+
+// Epoch returns the random ID of the bus, which changes every time the service starts, so an event can be identified
+// by its epoch and ID across restarts
+func (b *EventBus) Epoch() uuid.UUID {
+	return b.epoch
+}
+
+// EventID returns the ID clients see for event, the bus's epoch, a colon and the event's ID, so IDs from before a
+// restart can't be mistaken for new events
+func (b *EventBus) EventID(event Event) string {
+	return b.epoch.String() + ":" + strconv.FormatUint(event.ID, 10)
+}
+
+// ParseEventID returns the ID of the event EventID returned value for. Returns ErrEventExpired if value is from
+// another epoch, such as before the service restarted.
+func (b *EventBus) ParseEventID(value string) (uint64, error) {
+	i := strings.LastIndexByte(value, ':')
+	if i < 0 {
+		return 0, fmt.Errorf("event ID %q must be an epoch and ID separated by a colon", value)
+	}
+	epoch, err := uuid.FromString(value[:i])
+	if err != nil {
+		return 0, fmt.Errorf("invalid epoch of event ID %q, %w", value, err)
+	}
+	id, err := strconv.ParseUint(value[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event ID %q, %w", value, err)
+	}
+	if !uuid.Equal(epoch, b.epoch) {
+		return 0, fmt.Errorf("event ID %q is from another epoch, %w", value, ErrEventExpired)
+	}
+	return id, nil
+}
+
+// Publish sends an event of the change to every subscriber and retains it in the history. Subscribers that have
+// fallen too far behind to accept it are dropped, they can resubscribe from the last event they received.
+func (b *EventBus) Publish(eventType EventType, id uuid.UUID, person *Person) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Time: b.now().UTC(), PersonID: id}
+	if person != nil {
+		event.Person = person.clone()
+	}
+
+	if b.maxHistory > 0 {
+		if len(b.history) == b.maxHistory {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, event)
+	}
+
+	for subscription := range b.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			b.unsubscribe(subscription)
+		}
+	}
+	return event
+}
+
+// LastID returns the ID of the most recent event, 0 if none have been published
+func (b *EventBus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Subscribe receives every event published after the event with ID after, starting with those still in the history.
+// Returns ErrEventExpired if events after it have already left the history, or if it was never published.
+func (b *EventBus) Subscribe(after uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldest := b.lastID + 1
+	if len(b.history) > 0 {
+		oldest = b.history[0].ID
+	}
+	if after > b.lastID || after+1 < oldest {
+		return nil, ErrEventExpired
+	}
+
+	missed := b.history[len(b.history)-int(b.lastID-after):]
+	subscription := &Subscription{bus: b, events: make(chan Event, len(missed)+subscriberBuffer)}
+	for _, event := range missed {
+		subscription.events <- event
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+func (b *EventBus) unsubscribe(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// Subscription receives the events of an EventBus
+type Subscription struct {
+	bus    *EventBus
+	events chan Event
+}
+
+// Events receives each event in order. It is closed by Close, or when the subscriber falls too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops receiving events
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribe(s)
+}
+
+// PublishingStore is a PersonStore that publishes an event of every change to an EventBus
+type PublishingStore struct {
+	PersonStore
+	bus *EventBus
+	// mu serializes changes so events are published in the order they were made
+	mu sync.Mutex
+}
+
+// NewPublishingStore wraps store, publishing its changes to bus
+func NewPublishingStore(store PersonStore, bus *EventBus) *PublishingStore {
+	return &PublishingStore{PersonStore: store, bus: bus}
+}
+
+func (s *PublishingStore) Create(person *Person) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revision, err := s.PersonStore.Create(person)
+	if err != nil {
+		return Revision{}, err
+	}
+	s.bus.Publish(EventPersonCreated, person.ID, person)
+	return revision, nil
+}
+
+func (s *PublishingStore) Update(person *Person) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PersonStore.Update(person); err != nil {
+		return err
+	}
+	s.bus.Publish(EventPersonUpdated, person.ID, person)
+	return nil
+}
+
+func (s *PublishingStore) Delete(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PersonStore.Delete(id); err != nil {
+		return err
+	}
+	s.bus.Publish(EventPersonDeleted, id, nil)
+	return nil
+}
+
+func (s *PublishingStore) UpdateRevision(person *Person, version uint64) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revision, err := s.PersonStore.UpdateRevision(person, version)
+	if err != nil {
+		return revision, err
+	}
+	s.bus.Publish(EventPersonUpdated, person.ID, person)
+	return revision, nil
+}
+
+func (s *PublishingStore) DeleteRevision(id uuid.UUID, version uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PersonStore.DeleteRevision(id, version); err != nil {
+		return err
+	}
+	s.bus.Publish(EventPersonDeleted, id, nil)
+	return nil
+}
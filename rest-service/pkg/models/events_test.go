@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// receive reads the next n events of subscription, failing if they don't arrive promptly
+func receive(t *testing.T, subscription *Subscription, n int) []Event {
+	events := make([]Event, 0, n)
+	for len(events) < n {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				t.Fatalf("subscription closed after %d events", len(events))
+			}
+			events = append(events, event)
+		case <-time.After(time.Second):
+			t.Fatalf("only received %d of %d events", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestEventBus(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	bus := NewEventBus(3)
+	bus.now = func() time.Time { return now }
+	person := SamplePeople()[0]
+
+	t.Run("Publish", func(t *testing.T) {
+		subscription, err := bus.Subscribe(bus.LastID())
+		assert.Nil(t, err)
+		defer subscription.Close()
+
+		event := bus.Publish(EventPersonCreated, person.ID, person)
+		person.FirstName = "Changed"
+
+		assert.Equal(t, Event{ID: 1, Type: EventPersonCreated, Time: now, PersonID: person.ID, Person: SamplePeople()[0]}, event)
+		assert.Equal(t, []Event{event}, receive(t, subscription, 1))
+		assert.Equal(t, uint64(1), bus.LastID())
+	})
+	t.Run("Resume From History", func(t *testing.T) {
+		bus.Publish(EventPersonUpdated, person.ID, person)
+		bus.Publish(EventPersonDeleted, person.ID, nil)
+
+		subscription, err := bus.Subscribe(1)
+		assert.Nil(t, err)
+		defer subscription.Close()
+		bus.Publish(EventPersonCreated, person.ID, person)
+
+		events := receive(t, subscription, 3)
+		assert.Equal(t, []uint64{2, 3, 4}, []uint64{events[0].ID, events[1].ID, events[2].ID})
+		assert.Nil(t, events[1].Person)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		// The history holds 2, 3 and 4
+		_, err := bus.Subscribe(0)
+		assert.Equal(t, ErrEventExpired, err)
+		_, err = bus.Subscribe(5)
+		assert.Equal(t, ErrEventExpired, err)
+
+		subscription, err := bus.Subscribe(1)
+		assert.Nil(t, err)
+		subscription.Close()
+	})
+	t.Run("Close", func(t *testing.T) {
+		subscription, err := bus.Subscribe(bus.LastID())
+		assert.Nil(t, err)
+		subscription.Close()
+		subscription.Close()
+
+		_, ok := <-subscription.Events()
+		assert.False(t, ok)
+	})
+	t.Run("Slow Subscriber Dropped", func(t *testing.T) {
+		subscription, err := bus.Subscribe(bus.LastID())
+		assert.Nil(t, err)
+		for i := 0; i <= subscriberBuffer; i++ {
+			bus.Publish(EventPersonUpdated, person.ID, person)
+		}
+
+		received := 0
+		for range subscription.Events() {
+			received++
+		}
+		assert.Equal(t, subscriberBuffer, received)
+	})
+	t.Run("No History", func(t *testing.T) {
+		bus := NewEventBus(0)
+		bus.Publish(EventPersonCreated, person.ID, person)
+
+		_, err := bus.Subscribe(0)
+		assert.Equal(t, ErrEventExpired, err)
+		subscription, err := bus.Subscribe(1)
+		assert.Nil(t, err)
+		subscription.Close()
+	})
+	t.Run("Event IDs", func(t *testing.T) {
+		bus := NewEventBus(DefaultEventHistory)
+		event := bus.Publish(EventPersonCreated, uuid.NewV4(), nil)
+
+		id, err := bus.ParseEventID(bus.EventID(event))
+		assert.Nil(t, err)
+		assert.Equal(t, event.ID, id)
+
+		// Another bus, as after a restart, gives the same event a different ID
+		restarted := NewEventBus(DefaultEventHistory)
+		assert.NotEqual(t, bus.EventID(event), restarted.EventID(event))
+		_, err = restarted.ParseEventID(bus.EventID(event))
+		assert.ErrorIs(t, err, ErrEventExpired)
+
+		for _, invalid := range []string{"", "1", "not-a-uuid:1", bus.Epoch().String() + ":-1"} {
+			_, err := bus.ParseEventID(invalid)
+			assert.NotNil(t, err)
+			assert.False(t, errors.Is(err, ErrEventExpired), invalid)
+		}
+	})
+}
+
+func TestPublishingStore(t *testing.T) {
+	bus := NewEventBus(DefaultEventHistory)
+	store := NewPublishingStore(NewMemoryStore(SamplePeople()...), bus)
+	subscription, err := bus.Subscribe(0)
+	assert.Nil(t, err)
+	defer subscription.Close()
+	id := uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81"))
+
+	person := &Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"}
+	_, err = store.Create(person)
+	assert.Nil(t, err)
+	changed, err := store.FindByID(id)
+	assert.Nil(t, err)
+	changed.LastName = "Roe"
+	assert.Nil(t, store.Update(changed))
+	_, err = store.UpdateRevision(changed, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, store.DeleteRevision(id, 3))
+	assert.Nil(t, store.Delete(person.ID))
+
+	// Failed changes aren't published
+	assert.True(t, errors.Is(store.Delete(id), ErrPersonNotFound))
+	_, err = store.Create(SamplePeople()[1])
+	assert.True(t, errors.Is(err, ErrPersonExists))
+	_, err = store.UpdateRevision(changed, 1)
+	assert.NotNil(t, err)
+
+	events := receive(t, subscription, 5)
+	assert.Equal(t, EventPersonCreated, events[0].Type)
+	assert.Equal(t, person.ID, events[0].PersonID)
+	assert.Equal(t, EventPersonUpdated, events[1].Type)
+	assert.Equal(t, "Roe", events[1].Person.LastName)
+	assert.Equal(t, EventPersonUpdated, events[2].Type)
+	assert.Equal(t, Event{ID: 4, Type: EventPersonDeleted, Time: events[3].Time, PersonID: id}, events[3])
+	assert.Equal(t, EventPersonDeleted, events[4].Type)
+	assert.Equal(t, person.ID, events[4].PersonID)
+	assert.Equal(t, uint64(5), bus.LastID())
+}
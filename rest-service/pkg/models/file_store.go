@@ -0,0 +1,358 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	fileOpPut    = "put"
+	fileOpDelete = "delete"
+)
+
+// fileRecord is a single line in the FileStore's append-only log. Deletes keep the version that was deleted. Logs
+// written before revisions were tracked have no version or update time, those puts are replayed as the next version
+// updated when the log was opened.
+type fileRecord struct {
+	Op        string     `json:"op"`
+	ID        uuid.UUID  `json:"id"`
+	Person    *Person    `json:"person,omitempty"`
+	Version   uint64     `json:"version,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// FileStore A PersonStore persisted to an append-only JSON-lines file.
+//
+// Every mutation is appended to the file and synced before it becomes visible, on open the file is replayed into
+// memory to serve reads. Compact can be used to rewrite the log with only the current state.
+type FileStore struct {
+	// writeMu serializes writers so the log order always matches the in-memory order
+	writeMu sync.Mutex
+	path    string
+	file    *os.File
+	memory  *MemoryStore
+	// failed is set once the log may no longer hold every change, later changes fail with it rather than being lost
+	failed error
+}
+
+// OpenFileStore opens, or creates, the log at path and replays it into memory.
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening people store %s, %w", path, err)
+	}
+
+	store := &FileStore{path: path, file: file, memory: NewMemoryStore()}
+	if err := store.replay(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error replaying people store %s, %w", path, err)
+	}
+
+	return store, nil
+}
+
+func (s *FileStore) All() ([]*Person, error) {
+	return s.memory.All()
+}
+
+func (s *FileStore) Each(size int, fn func([]*Person) error) error {
+	return s.memory.Each(size, fn)
+}
+
+func (s *FileStore) Count() (int, error) {
+	return s.memory.Count()
+}
+
+func (s *FileStore) FindByID(id uuid.UUID) (*Person, error) {
+	return s.memory.FindByID(id)
+}
+
+func (s *FileStore) FindRevisionByID(id uuid.UUID) (*Person, Revision, error) {
+	return s.memory.FindRevisionByID(id)
+}
+
+func (s *FileStore) Search(query Query) ([]*Person, error) {
+	return s.memory.Search(query)
+}
+
+func (s *FileStore) Create(person *Person) (Revision, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if uuid.Equal(person.ID, uuid.Nil) {
+		person.ID = uuid.NewV4()
+	}
+	if _, err := s.memory.FindByID(person.ID); err == nil {
+		return Revision{}, fmt.Errorf("user ID %s, %w", person.ID.String(), ErrPersonExists)
+	}
+
+	revision := s.firstRevision(person.ID)
+	if err := s.append(putRecord(person, revision)); err != nil {
+		return Revision{}, err
+	}
+	s.memory.restore(person, revision)
+	return revision, nil
+}
+
+func (s *FileStore) Update(person *Person) error {
+	_, err := s.UpdateRevision(person, AnyRevision)
+	return err
+}
+
+func (s *FileStore) Delete(id uuid.UUID) error {
+	return s.DeleteRevision(id, AnyRevision)
+}
+
+func (s *FileStore) UpdateRevision(person *Person, version uint64) (Revision, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	entry, err := s.entryAtVersion(person.ID, version)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	revision := s.memory.nextRevision(person.ID, &entry)
+	if err := s.append(putRecord(person, revision)); err != nil {
+		return Revision{}, err
+	}
+	s.memory.restore(person, revision)
+	return revision, nil
+}
+
+func (s *FileStore) DeleteRevision(id uuid.UUID, version uint64) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	entry, err := s.entryAtVersion(id, version)
+	if err != nil {
+		return err
+	}
+
+	// The version is kept so a person created again with the ID continues from it, even once the log is compacted
+	if err := s.append(deleteRecord(id, entry.revision.Version)); err != nil {
+		return err
+	}
+	return s.memory.Delete(id)
+}
+
+// firstRevision returns the revision of a new person with the ID
+func (s *FileStore) firstRevision(id uuid.UUID) Revision {
+	s.memory.mu.RLock()
+	defer s.memory.mu.RUnlock()
+
+	return s.memory.nextRevision(id, nil)
+}
+
+// entryAtVersion returns a copy of the in-memory entry if it is at version. The caller must hold writeMu, which keeps
+// the entry current until it is released.
+func (s *FileStore) entryAtVersion(id uuid.UUID, version uint64) (memoryEntry, error) {
+	s.memory.mu.RLock()
+	defer s.memory.mu.RUnlock()
+
+	entry, err := s.memory.entryAtVersion(id, version)
+	if err != nil {
+		return memoryEntry{}, err
+	}
+	return *entry, nil
+}
+
+// putRecord returns the log record storing the person at revision
+func putRecord(person *Person, revision Revision) fileRecord {
+	return fileRecord{Op: fileOpPut, ID: person.ID, Person: person, Version: revision.Version, UpdatedAt: &revision.UpdatedAt}
+}
+
+// deleteRecord returns the log record deleting the person with the ID at version
+func deleteRecord(id uuid.UUID, version uint64) fileRecord {
+	return fileRecord{Op: fileOpDelete, ID: id, Version: version}
+}
+
+// Compact rewrites the log so it only contains the current state of every person, and the last version of every
+// deleted person.
+func (s *FileStore) Compact() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	people, err := s.memory.All()
+	if err != nil {
+		return err
+	}
+
+	// The compacted copy is opened for appending up front so it becomes the log as soon as it is renamed, there is
+	// nothing left to fail between replacing the log and writing to it
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating compacted people store, %w", err)
+	}
+	abandon := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	encoder := json.NewEncoder(tmp)
+	for _, person := range people {
+		_, revision, err := s.memory.FindRevisionByID(person.ID)
+		if err != nil {
+			return abandon(err)
+		}
+		if err := encoder.Encode(putRecord(person, revision)); err != nil {
+			return abandon(fmt.Errorf("error writting compacted people store, %w", err))
+		}
+	}
+	for id, version := range s.memory.retiredVersions() {
+		if err := encoder.Encode(deleteRecord(id, version)); err != nil {
+			return abandon(fmt.Errorf("error writting compacted people store, %w", err))
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return abandon(fmt.Errorf("error syncing compacted people store, %w", err))
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return abandon(fmt.Errorf("error replacing people store with compacted copy, %w", err))
+	}
+	_ = s.file.Close()
+	s.file = tmp
+
+	// Until the rename is synced a crash could bring back the old log, losing every change appended to the new one
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		s.failed = fmt.Errorf("error syncing compacted people store, %w", err)
+		return s.failed
+	}
+	return nil
+}
+
+// syncDir syncs the entries of a directory to disk
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dir.Close() }()
+	return dir.Sync()
+}
+
+// Close closes the underlying log file, the store must not be used afterwards.
+func (s *FileStore) Close() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.file.Close()
+}
+
+// append writes a record to the end of the log and syncs it to disk. The caller must hold writeMu.
+func (s *FileStore) append(record fileRecord) error {
+	if s.failed != nil {
+		return fmt.Errorf("people store can no longer be changed, %w", s.failed)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding people store record, %w", err)
+	}
+
+	// A failed write may leave part of the record behind, anything appended after it would be unreadable
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		s.failed = fmt.Errorf("error writting people store record, %w", err)
+		return s.failed
+	}
+	if err := s.file.Sync(); err != nil {
+		s.failed = fmt.Errorf("error syncing people store, %w", err)
+		return s.failed
+	}
+	return nil
+}
+
+// replayedRevision returns the revision of a put record, defaulting to the next version for old records
+func (s *FileStore) replayedRevision(record fileRecord) Revision {
+	s.memory.mu.RLock()
+	defer s.memory.mu.RUnlock()
+
+	revision := s.memory.nextRevision(record.ID, s.memory.byID[record.ID])
+	if record.Version != 0 {
+		revision.Version = record.Version
+	}
+	if record.UpdatedAt != nil {
+		revision.UpdatedAt = record.UpdatedAt.UTC()
+	}
+	return revision
+}
+
+// replay applies every record in the log to the in-memory store. A record cut short by a crash while it was appended is
+// discarded when nothing follows it, it was never acknowledged. Invalid records followed by valid ones are an error.
+func (s *FileStore) replay() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("error reading record %d, %w", line, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			if err != nil {
+				return nil
+			}
+			offset += int64(len(data))
+			continue
+		}
+
+		var record fileRecord
+		if decodeErr := json.Unmarshal(data, &record); decodeErr != nil {
+			rest, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return fmt.Errorf("error reading record %d, %w", line+1, err)
+			}
+			if len(bytes.TrimSpace(rest)) > 0 {
+				return fmt.Errorf("invalid record %d, %w", line, decodeErr)
+			}
+			return s.truncate(offset, line)
+		}
+		if data[len(data)-1] != '\n' {
+			// The record was written but not the newline ending it
+			if _, err := s.file.Write([]byte{'\n'}); err != nil {
+				return fmt.Errorf("error completing record %d, %w", line, err)
+			}
+		}
+		offset += int64(len(data))
+
+		switch record.Op {
+		case fileOpPut:
+			if record.Person == nil {
+				return fmt.Errorf("invalid record %d, missing person", line)
+			}
+			record.Person.ID = record.ID
+			s.memory.restore(record.Person, s.replayedRevision(record))
+		case fileOpDelete:
+			// A missing person means the log was already compacted past it, only their version is left
+			s.memory.retire(record.ID, record.Version)
+		default:
+			return fmt.Errorf("invalid record %d, unknown operation %q", line, record.Op)
+		}
+	}
+}
+
+// truncate discards the incomplete record on line and everything after it, which starts at offset
+func (s *FileStore) truncate(offset int64, line int) error {
+	log.Printf("Discarding incomplete record %d at the end of people store %s\n", line, s.path)
+	if err := s.file.Truncate(offset); err != nil {
+		return fmt.Errorf("error discarding incomplete record %d, %w", line, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("error discarding incomplete record %d, %w", line, err)
+	}
+	return nil
+}
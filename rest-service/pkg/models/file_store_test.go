@@ -0,0 +1,221 @@
+package models
+
+import (
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "people")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "people.jsonl")
+
+	store, err := OpenFileStore(path)
+	assert.Nil(t, err)
+	for _, person := range SamplePeople() {
+		_, err := store.Create(person)
+		assert.Nil(t, err)
+	}
+
+	jack := &Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"}
+	_, err = store.Create(jack)
+	assert.Nil(t, err)
+	jack.PhoneNumber = "+1 (800) 555-1616"
+	assert.Nil(t, store.Update(jack))
+	assert.Nil(t, store.Delete(uuid.Must(uuid.FromString("df12ce76-767b-4bf0-bccb-816745df9e70"))))
+	_, err = store.Create(&Person{ID: jack.ID})
+	assert.ErrorIs(t, err, ErrPersonExists)
+	assert.Nil(t, store.Close())
+
+	t.Run("Reopen", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		results, err := store.All()
+		assert.Nil(t, err)
+		assert.Len(t, results, 5)
+
+		found, err := store.FindByID(jack.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "+1 (800) 555-1616", found.PhoneNumber)
+
+		_, err = store.FindByID(uuid.Must(uuid.FromString("df12ce76-767b-4bf0-bccb-816745df9e70")))
+		assert.ErrorIs(t, err, ErrPersonNotFound)
+	})
+	t.Run("Compact", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.Nil(t, err)
+
+		before, err := store.All()
+		assert.Nil(t, err)
+		assert.Nil(t, store.Compact())
+		assert.Nil(t, store.Delete(jack.ID))
+		assert.Nil(t, store.Close())
+
+		store, err = OpenFileStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		after, err := store.All()
+		assert.Nil(t, err)
+		assert.Equal(t, before[:len(before)-1], after)
+	})
+	t.Run("Compact Failure", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		// The log can't be replaced by renaming over a directory
+		store.path = t.TempDir()
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(store.path, "keep"), nil, 0600))
+		assert.NotNil(t, store.Compact())
+		_, err = os.Stat(store.path + ".compact")
+		assert.True(t, os.IsNotExist(err))
+
+		// Changes are still appended to the original log
+		_, err = store.Create(&Person{FirstName: "Jill", LastName: "Doe", PhoneNumber: "+1 (800) 555-1717"})
+		assert.Nil(t, err)
+		reopened, err := OpenFileStore(path)
+		assert.Nil(t, err)
+		defer reopened.Close()
+		count, err := reopened.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 5, count)
+	})
+	t.Run("Write Failure", func(t *testing.T) {
+		store, err := OpenFileStore(filepath.Join(t.TempDir(), "people.jsonl"))
+		assert.Nil(t, err)
+		assert.Nil(t, store.file.Close())
+
+		_, err = store.Create(&Person{FirstName: "Jack"})
+		assert.NotNil(t, err)
+		assert.NotNil(t, store.failed)
+		// Later changes fail rather than being appended after a partial record
+		_, err = store.Create(&Person{FirstName: "Jill"})
+		assert.Contains(t, err.Error(), "can no longer be changed")
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		corrupt := filepath.Join(dir, "corrupt.jsonl")
+		assert.Nil(t, ioutil.WriteFile(corrupt, []byte("{\"op\":\"unknown\"}\n"), 0600))
+
+		_, err := OpenFileStore(corrupt)
+		assert.NotNil(t, err)
+
+		// A damaged record with valid ones after it isn't a crash mid-append
+		john := `{"op":"put","id":"81eb745b-3aae-400b-959f-748fcafafd81","person":{"first_name":"John","last_name":"Doe","phone_number":"+1 (800) 555-1212"}}` + "\n"
+		assert.Nil(t, ioutil.WriteFile(corrupt, []byte(`{"op":"put","id":`+"\n"+john), 0600))
+		_, err = OpenFileStore(corrupt)
+		assert.NotNil(t, err)
+	})
+	t.Run("Incomplete Last Record", func(t *testing.T) {
+		john := `{"op":"put","id":"81eb745b-3aae-400b-959f-748fcafafd81","person":{"first_name":"John","last_name":"Doe","phone_number":"+1 (800) 555-1212"}}` + "\n"
+		torn := filepath.Join(t.TempDir(), "torn.jsonl")
+		assert.Nil(t, ioutil.WriteFile(torn, []byte(john+`{"op":"put","id":"5b81b629-9026`), 0600))
+
+		store, err := OpenFileStore(torn)
+		assert.Nil(t, err)
+		contents, err := ioutil.ReadFile(torn)
+		assert.Nil(t, err)
+		assert.Equal(t, john, string(contents))
+
+		// New records follow the last complete one
+		_, err = store.Create(&Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"})
+		assert.Nil(t, err)
+		assert.Nil(t, store.Close())
+		store, err = OpenFileStore(torn)
+		assert.Nil(t, err)
+		defer store.Close()
+		count, err := store.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+	})
+	t.Run("Missing Final Newline", func(t *testing.T) {
+		john := `{"op":"put","id":"81eb745b-3aae-400b-959f-748fcafafd81","person":{"first_name":"John","last_name":"Doe","phone_number":"+1 (800) 555-1212"}}`
+		unterminated := filepath.Join(t.TempDir(), "unterminated.jsonl")
+		assert.Nil(t, ioutil.WriteFile(unterminated, []byte(john), 0600))
+
+		store, err := OpenFileStore(unterminated)
+		assert.Nil(t, err)
+		defer store.Close()
+		count, err := store.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		contents, err := ioutil.ReadFile(unterminated)
+		assert.Nil(t, err)
+		assert.Equal(t, john+"\n", string(contents))
+	})
+}
+
+func TestFileStore_Revisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.jsonl")
+	store, err := OpenFileStore(path)
+	assert.Nil(t, err)
+
+	jack := &Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"}
+	_, err = store.Create(jack)
+	assert.Nil(t, err)
+	jack.LastName = "Roe"
+	revision, err := store.UpdateRevision(jack, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), revision.Version)
+	_, err = store.UpdateRevision(jack, 1)
+	assert.ErrorIs(t, err, ErrRevisionMismatch)
+	assert.ErrorIs(t, store.DeleteRevision(jack.ID, 1), ErrRevisionMismatch)
+	assert.Nil(t, store.Close())
+
+	t.Run("Persisted", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		_, reopened, err := store.FindRevisionByID(jack.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, revision, reopened)
+
+		assert.Nil(t, store.Compact())
+		_, compacted, err := store.FindRevisionByID(jack.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, revision, compacted)
+	})
+	t.Run("Recreated After Compaction", func(t *testing.T) {
+		recreated := filepath.Join(t.TempDir(), "people.jsonl")
+		store, err := OpenFileStore(recreated)
+		assert.Nil(t, err)
+		jill := &Person{FirstName: "Jill", LastName: "Doe", PhoneNumber: "+1 (800) 555-1717"}
+		_, err = store.Create(jill)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Delete(jill.ID))
+		// Compacting drops the put but keeps the deleted version
+		assert.Nil(t, store.Compact())
+		assert.Nil(t, store.Close())
+
+		store, err = OpenFileStore(recreated)
+		assert.Nil(t, err)
+		defer store.Close()
+		revision, err := store.Create(&Person{ID: jill.ID, FirstName: "Jill", LastName: "Roe", PhoneNumber: "+1 (800) 555-1717"})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(2), revision.Version)
+	})
+	t.Run("Records Without Revisions", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), "people.jsonl")
+		assert.Nil(t, ioutil.WriteFile(legacy, []byte(
+			`{"op":"put","id":"81eb745b-3aae-400b-959f-748fcafafd81","person":{"first_name":"John","last_name":"Doe","phone_number":"+1 (800) 555-1212"}}`+"\n"+
+				`{"op":"put","id":"81eb745b-3aae-400b-959f-748fcafafd81","person":{"first_name":"John","last_name":"Roe","phone_number":"+1 (800) 555-1212"}}`+"\n"), 0600))
+
+		store, err := OpenFileStore(legacy)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		person, revision, err := store.FindRevisionByID(uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81")))
+		assert.Nil(t, err)
+		assert.Equal(t, "Roe", person.LastName)
+		assert.Equal(t, uint64(2), revision.Version)
+		assert.False(t, revision.UpdatedAt.IsZero())
+	})
+}
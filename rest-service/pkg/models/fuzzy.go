@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/xml"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MinFuzzyTokenScore is the lowest score a query token may have against a person for the person to match
+	MinFuzzyTokenScore = 0.6
+	// minPhoneFragment is the fewest digits a query token needs to be matched against phone numbers
+	minPhoneFragment = 3
+	// prefixPenalty scales the score of a query token that only matches the start of a name
+	prefixPenalty = 0.9
+)
+
+// ScoredPerson is a fuzzy search result, Score ranges from 0 to 1 with 1 being an exact match
+type ScoredPerson struct {
+	XMLName xml.Name `json:"-" xml:"person"`
+	*Person
+	Score float64 `json:"score" xml:"score"`
+}
+
+// FuzzySearch performs a tokenized, typo-tolerant search over the names and phone numbers of people.
+//
+// The query is split into tokens which are each compared against the first name, last name and phone number of every
+// person. Name tokens are compared by edit distance and trigram similarity so small typos and partial names still
+// match, number tokens match any phone number containing those digits. A person matches when every token scores at
+// least MinFuzzyTokenScore against one of their fields, and is ranked by the average token score. Results are
+// ordered by score with ties kept in their original order.
+func FuzzySearch(people []*Person, query string) []ScoredPerson {
+	tokens := tokenize(query)
+	results := make([]ScoredPerson, 0)
+	if len(tokens) == 0 {
+		return results
+	}
+
+	for _, person := range people {
+		if score, ok := fuzzyScore(person, tokens); ok {
+			results = append(results, ScoredPerson{Person: person, Score: math.Round(score*1000) / 1000})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+// HasPhoneFragment reports whether a FuzzySearch query contains a number long enough to be matched against phone numbers
+func HasPhoneFragment(query string) bool {
+	for _, token := range tokenize(query) {
+		if isDigits(token) && len(token) >= minPhoneFragment {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyScore returns the average best score of every token, and false if any token didn't match the person
+func fuzzyScore(person *Person, tokens []string) (float64, bool) {
+	names := append(tokenize(person.FirstName), tokenize(person.LastName)...)
+	digits := phoneDigits(normalizedPhoneNumber(person.PhoneNumber))
+
+	total := 0.0
+	for _, token := range tokens {
+		best := 0.0
+		if isDigits(token) {
+			if len(token) >= minPhoneFragment && strings.Contains(digits, token) {
+				best = 1
+			}
+		} else {
+			for _, name := range names {
+				best = math.Max(best, nameSimilarity(token, name))
+			}
+		}
+
+		if best < MinFuzzyTokenScore {
+			return 0, false
+		}
+		total += best
+	}
+	return total / float64(len(tokens)), true
+}
+
+// nameSimilarity scores how closely a query token matches a name, allowing for typos and partially typed names
+func nameSimilarity(token, name string) float64 {
+	if token == name {
+		return 1
+	}
+
+	score := math.Max(editSimilarity(token, name), trigramSimilarity(token, name))
+	if tokenRunes, nameRunes := []rune(token), []rune(name); len(nameRunes) > len(tokenRunes) {
+		// Compare against the start of the name so "jonat" still finds "jonathan"
+		score = math.Max(score, editSimilarity(token, string(nameRunes[:len(tokenRunes)]))*prefixPenalty)
+	}
+	return score
+}
+
+// editSimilarity converts the Levenshtein distance between a and b to a score between 0 and 1
+func editSimilarity(a, b string) float64 {
+	ar, br := []rune(a), []rune(b)
+	longest := len(ar)
+	if len(br) > longest {
+		longest = len(br)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ar, br))/float64(longest)
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions to turn a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// trigramSimilarity returns the Jaccard similarity of the padded trigram sets of a and b
+func trigramSimilarity(a, b string) float64 {
+	aGrams, bGrams := trigrams(a), trigrams(b)
+	shared := 0
+	for gram := range aGrams {
+		if bGrams[gram] {
+			shared++
+		}
+	}
+
+	union := len(aGrams) + len(bGrams) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+func trigrams(value string) map[string]bool {
+	runes := []rune("  " + value + " ")
+	result := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		result[string(runes[i:i+3])] = true
+	}
+	return result
+}
+
+// tokenize splits value into lower case words and numbers. Consecutive numbers are joined so a phone number written
+// as "+1 (800) 555-1212" is a single token.
+func tokenize(value string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '\''
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if last := len(tokens) - 1; last >= 0 && isDigits(field) && isDigits(tokens[last]) {
+			tokens[last] += field
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
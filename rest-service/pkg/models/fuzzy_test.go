@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFuzzySearch(t *testing.T) {
+	search := func(query string) []string {
+		names := make([]string, 0)
+		for _, result := range FuzzySearch(SamplePeople(), query) {
+			names = append(names, result.FirstName+" "+result.LastName+" "+result.PhoneNumber)
+		}
+		return names
+	}
+
+	t.Run("Exact Name", func(t *testing.T) {
+		results := FuzzySearch(SamplePeople(), "Jenny Smith")
+
+		assert.Len(t, results, 1)
+		assert.Equal(t, "Jenny", results[0].FirstName)
+		assert.Equal(t, 1.0, results[0].Score)
+	})
+	t.Run("Typo", func(t *testing.T) {
+		assert.Equal(t, []string{"Jenny Smith +44 7700 900077"}, search("jenny smiht"))
+		assert.Equal(t, []string{"Brian Smith +44 7700 900077"}, search("Brain"))
+	})
+	t.Run("Partial Name", func(t *testing.T) {
+		assert.Equal(t, []string{"Brian Smith +44 7700 900077", "Jenny Smith +44 7700 900077"}, search("smi"))
+	})
+	t.Run("Ranked", func(t *testing.T) {
+		people := []*Person{
+			{FirstName: "Jon", LastName: "Doe"},
+			{FirstName: "Johnny", LastName: "Doe"},
+			{FirstName: "Jane", LastName: "Doe"},
+			{FirstName: "John", LastName: "Doe"},
+		}
+		results := FuzzySearch(people, "john doe")
+
+		assert.Len(t, results, 3)
+		assert.Equal(t, ScoredPerson{Person: people[3], Score: 1}, results[0])
+		assert.Equal(t, ScoredPerson{Person: people[1], Score: 0.95}, results[1])
+		assert.Equal(t, ScoredPerson{Person: people[0], Score: 0.875}, results[2])
+	})
+	t.Run("Phone Fragment", func(t *testing.T) {
+		assert.Equal(t, []string{"John Doe +1 (800) 555-1414"}, search("555-1414"))
+		assert.Equal(t, []string{"John Doe +1 (800) 555-1212"}, search("+1 (800) 555 1212"))
+		assert.Equal(t, []string{"Jenny Smith +44 7700 900077"}, search("jenny 900077"))
+	})
+	t.Run("No Match", func(t *testing.T) {
+		assert.Len(t, search("zebedee"), 0)
+		assert.Len(t, search("12"), 0)
+		assert.Len(t, search("  "), 0)
+	})
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein([]rune("smith"), []rune("smith")))
+	assert.Equal(t, 2, levenshtein([]rune("smith"), []rune("smiht")))
+	assert.Equal(t, 3, levenshtein([]rune("kitten"), []rune("sitting")))
+	assert.Equal(t, 5, levenshtein([]rune(""), []rune("smith")))
+}
+
+func TestHasPhoneFragment(t *testing.T) {
+	assert.True(t, HasPhoneFragment("smith 7700"))
+	assert.True(t, HasPhoneFragment("+1 (800) 555"))
+	assert.False(t, HasPhoneFragment("jane 12"))
+	assert.False(t, HasPhoneFragment("jane smith"))
+}
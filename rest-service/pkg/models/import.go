@@ -0,0 +1,49 @@
+package models
+
+import "encoding/xml"
+
+// MaxImportErrors is the most failed records listed in an ImportReport
+const MaxImportErrors = 1000
+
+// ImportReport summarizes a bulk import of people
+type ImportReport struct {
+	XMLName  xml.Name `json:"-" xml:"import"`
+	Imported int      `json:"imported" xml:"imported"`
+	Failed   int      `json:"failed" xml:"failed"`
+	// Complete is false when the import stopped early, because the stream couldn't be read to the end or the imported
+	// people couldn't be audited. The records after that point weren't imported.
+	Complete bool          `json:"complete" xml:"complete"`
+	Errors   []ImportError `json:"errors" xml:"errors>error"`
+	// Truncated is set when more records failed than are listed in Errors
+	Truncated bool `json:"truncated" xml:"truncated"`
+}
+
+// ImportError describes why a single record wasn't imported
+type ImportError struct {
+	// Record is the position of the record in the stream starting from 1, not counting CSV headers or blank lines
+	Record  int           `json:"record" xml:"record"`
+	Code    ErrorCode     `json:"code" xml:"code"`
+	Message string        `json:"message" xml:"message"`
+	Details []ErrorDetail `json:"details,omitempty" xml:"details>detail,omitempty"`
+}
+
+// Fail records that the record wasn't imported, listing it unless MaxImportErrors have already been listed
+func (r *ImportReport) Fail(record int, code ErrorCode, message string, details []ErrorDetail) {
+	r.Failed++
+	if len(r.Errors) >= MaxImportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportError{Record: record, Code: code, Message: message, Details: details})
+}
+
+// Stop records that the import stopped at the record for a reason other than the record itself, listing why without
+// counting it as failed
+func (r *ImportReport) Stop(record int, code ErrorCode, message string) {
+	r.Complete = false
+	if len(r.Errors) >= MaxImportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportError{Record: record, Code: code, Message: message})
+}
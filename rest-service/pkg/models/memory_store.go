@@ -0,0 +1,416 @@
+package models
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// MemoryStore A PersonStore that keeps everything in memory, nothing survives a restart.
+//
+// People are indexed by ID, by case-folded full name and by E.164 phone number so that exact lookups don't need to scan
+// every person. All indexes are updated together under the write lock so readers always see them consistent.
+type MemoryStore struct {
+	mu sync.RWMutex
+	// order holds every *memoryEntry in insertion order
+	order *list.List
+	// nextSeq is the insertion sequence given to the next new person
+	nextSeq uint64
+
+	byID    map[uuid.UUID]*memoryEntry
+	byName  map[string]entrySet
+	byPhone map[string]entrySet
+	// retired is the last version of every deleted person, a person created again with their ID continues from it so
+	// no revision ever identifies two different people
+	retired map[uuid.UUID]uint64
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// memoryEntry is a stored person along with its index keys
+type memoryEntry struct {
+	person *Person
+	// seq orders entries by insertion
+	seq     uint64
+	element *list.Element
+	// nameKey is the byName index key, see nameKey
+	nameKey string
+	// phoneNumber is the E.164 form of person.PhoneNumber, or the original if it could not be normalized
+	phoneNumber string
+	revision    Revision
+}
+
+// entrySet is the set of entries sharing a secondary index key
+type entrySet map[uuid.UUID]*memoryEntry
+
+// NewMemoryStore creates a MemoryStore seeded with copies of the given people.
+func NewMemoryStore(seed ...*Person) *MemoryStore {
+	store := &MemoryStore{
+		order:   list.New(),
+		byID:    make(map[uuid.UUID]*memoryEntry, len(seed)),
+		byName:  make(map[string]entrySet),
+		byPhone: make(map[string]entrySet),
+		retired: make(map[uuid.UUID]uint64),
+		now:     time.Now,
+	}
+	for _, person := range seed {
+		entry := store.byID[person.ID]
+		if entry != nil {
+			store.replace(entry, person, store.nextRevision(person.ID, entry))
+		} else {
+			store.insert(person, store.nextRevision(person.ID, nil))
+		}
+	}
+	return store
+}
+
+// normalizedPhoneNumber returns the E.164 form of phoneNumber, falling back to the original if it isn't valid so that
+// it can still be matched exactly
+func normalizedPhoneNumber(phoneNumber string) string {
+	if normalized, err := NormalizePhoneNumber(phoneNumber); err == nil {
+		return normalized
+	}
+	return phoneNumber
+}
+
+// nameKey returns the byName index key, names are case-folded so case-insensitive lookups can use the index too
+func nameKey(firstName, lastName string) string {
+	return strings.ToLower(firstName) + "\x00" + strings.ToLower(lastName)
+}
+
+func (s *MemoryStore) All() ([]*Person, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scan(Query{}), nil
+}
+
+func (s *MemoryStore) Each(size int, fn func([]*Person) error) error {
+	if size < 1 {
+		size = 1
+	}
+	var after *pageCursor
+	for {
+		people, next := s.page(after, size)
+		if len(people) == 0 {
+			return nil
+		}
+		if err := fn(people); err != nil {
+			return err
+		}
+		after = next
+	}
+}
+
+// pageCursor is the last entry of a page copied by page
+type pageCursor struct {
+	id  uuid.UUID
+	seq uint64
+}
+
+// page copies up to size people inserted after the entry at cursor, or from the start when it is nil, returning the
+// cursor of the last one
+func (s *MemoryStore) page(after *pageCursor, size int) ([]*Person, *pageCursor) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var element *list.Element
+	if after == nil {
+		element = s.order.Front()
+	} else if entry, ok := s.byID[after.id]; ok && entry.seq == after.seq {
+		element = entry.element.Next()
+	} else {
+		// Deleted since its page was copied, carry on from the first person inserted after it
+		element = s.order.Front()
+		for element != nil && element.Value.(*memoryEntry).seq <= after.seq {
+			element = element.Next()
+		}
+	}
+
+	people := make([]*Person, 0, size)
+	var last *memoryEntry
+	for ; element != nil && len(people) < size; element = element.Next() {
+		last = element.Value.(*memoryEntry)
+		people = append(people, last.person.clone())
+	}
+	if last == nil {
+		return people, after
+	}
+	return people, &pageCursor{id: last.person.ID, seq: last.seq}
+}
+
+func (s *MemoryStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.byID), nil
+}
+
+func (s *MemoryStore) FindByID(id uuid.UUID) (*Person, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.byID[id]; ok {
+		return entry.person.clone(), nil
+	}
+	return nil, fmt.Errorf("user ID %s not found, %w", id.String(), ErrPersonNotFound)
+}
+
+func (s *MemoryStore) FindRevisionByID(id uuid.UUID) (*Person, Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.byID[id]; ok {
+		return entry.person.clone(), entry.revision, nil
+	}
+	return nil, Revision{}, fmt.Errorf("user ID %s not found, %w", id.String(), ErrPersonNotFound)
+}
+
+// Search uses the name index when the query has exact first and last name filters, or the phone index for an exact
+// phone number filter, and otherwise scans every person.
+func (s *MemoryStore) Search(query Query) ([]*Person, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates, indexed := s.candidates(query)
+	if !indexed {
+		return s.scan(query), nil
+	}
+
+	matched := make([]*memoryEntry, 0, len(candidates))
+	for _, entry := range candidates {
+		if query.matches(entry.person, entry.phoneNumber) {
+			matched = append(matched, entry)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq < matched[j].seq })
+
+	result := make([]*Person, 0, len(matched))
+	for _, entry := range matched {
+		result = append(result, entry.person.clone())
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Create(person *Person) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if uuid.Equal(person.ID, uuid.Nil) {
+		person.ID = uuid.NewV4()
+	}
+	if _, ok := s.byID[person.ID]; ok {
+		return Revision{}, fmt.Errorf("user ID %s, %w", person.ID.String(), ErrPersonExists)
+	}
+
+	revision := s.nextRevision(person.ID, nil)
+	s.insert(person, revision)
+	return revision, nil
+}
+
+func (s *MemoryStore) Update(person *Person) error {
+	_, err := s.UpdateRevision(person, AnyRevision)
+	return err
+}
+
+func (s *MemoryStore) Delete(id uuid.UUID) error {
+	return s.DeleteRevision(id, AnyRevision)
+}
+
+func (s *MemoryStore) UpdateRevision(person *Person, version uint64) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.entryAtVersion(person.ID, version)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	revision := s.nextRevision(person.ID, entry)
+	s.replace(entry, person, revision)
+	return revision, nil
+}
+
+func (s *MemoryStore) DeleteRevision(id uuid.UUID, version uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.entryAtVersion(id, version)
+	if err != nil {
+		return err
+	}
+
+	s.remove(entry)
+	return nil
+}
+
+// entryAtVersion returns the entry with the ID if it is at version, or any version for AnyRevision. The caller must
+// hold the lock.
+func (s *MemoryStore) entryAtVersion(id uuid.UUID, version uint64) (*memoryEntry, error) {
+	entry, ok := s.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("user ID %s not found, %w", id.String(), ErrPersonNotFound)
+	}
+	if version != AnyRevision && entry.revision.Version != version {
+		return nil, fmt.Errorf("user ID %s is at version %d not %d, %w", id.String(), entry.revision.Version, version, ErrRevisionMismatch)
+	}
+	return entry, nil
+}
+
+// nextRevision returns the revision following the entry's, or the first revision of a new entry with the ID, which
+// follows the last version of any deleted person with it. The caller must hold the lock.
+func (s *MemoryStore) nextRevision(id uuid.UUID, entry *memoryEntry) Revision {
+	revision := Revision{Version: s.retired[id] + 1, UpdatedAt: s.now().UTC()}
+	if entry != nil {
+		revision.Version = entry.revision.Version + 1
+	}
+	return revision
+}
+
+// restore stores the person at exactly the revision, creating or replacing it. Used to load people whose revisions
+// were persisted elsewhere.
+func (s *MemoryStore) restore(person *Person, revision Revision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.byID[person.ID]; ok {
+		s.replace(entry, person, revision)
+	} else {
+		s.insert(person, revision)
+	}
+}
+
+// retire deletes the person with the ID, if they still exist, and records that their last version was at least
+// version. Used to load deletions that were persisted elsewhere.
+func (s *MemoryStore) retire(id uuid.UUID, version uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.byID[id]; ok {
+		s.remove(entry)
+	}
+	if version > s.retired[id] {
+		s.retired[id] = version
+	}
+}
+
+// retiredVersions returns a copy of the last version of every deleted person
+func (s *MemoryStore) retiredVersions() map[uuid.UUID]uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	retired := make(map[uuid.UUID]uint64, len(s.retired))
+	for id, version := range s.retired {
+		retired[id] = version
+	}
+	return retired
+}
+
+// remove deletes the entry from the store, retiring its version. The caller must hold the write lock.
+func (s *MemoryStore) remove(entry *memoryEntry) {
+	s.unindex(entry)
+	delete(s.byID, entry.person.ID)
+	s.order.Remove(entry.element)
+	s.retired[entry.person.ID] = entry.revision.Version
+}
+
+// candidates returns the entries that could match the query from the smallest usable index. The caller must hold the
+// lock.
+func (s *MemoryStore) candidates(query Query) (entrySet, bool) {
+	var firstName, lastName *Filter
+	var candidates entrySet
+	indexed := false
+	for i := range query.Filters {
+		filter := &query.Filters[i]
+		if filter.Prefix {
+			continue
+		}
+
+		switch filter.Field {
+		case FieldFirstName:
+			firstName = filter
+		case FieldLastName:
+			lastName = filter
+		case FieldPhoneNumber:
+			if set := s.byPhone[filter.Value]; !indexed || len(set) < len(candidates) {
+				candidates, indexed = set, true
+			}
+		}
+	}
+
+	if firstName != nil && lastName != nil {
+		if set := s.byName[nameKey(firstName.Value, lastName.Value)]; !indexed || len(set) < len(candidates) {
+			candidates, indexed = set, true
+		}
+	}
+	return candidates, indexed
+}
+
+// scan returns copies of every person matching the query in insertion order without using the secondary indexes. The
+// caller must hold the lock.
+func (s *MemoryStore) scan(query Query) []*Person {
+	result := make([]*Person, 0)
+	for element := s.order.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*memoryEntry)
+		if query.matches(entry.person, entry.phoneNumber) {
+			result = append(result, entry.person.clone())
+		}
+	}
+	return result
+}
+
+// insert adds a new person at the end of the insertion order. The caller must hold the write lock.
+func (s *MemoryStore) insert(person *Person, revision Revision) {
+	delete(s.retired, person.ID)
+	entry := &memoryEntry{seq: s.nextSeq, revision: revision}
+	s.nextSeq++
+	entry.element = s.order.PushBack(entry)
+	s.byID[person.ID] = entry
+	s.index(entry, person)
+}
+
+// replace swaps the person stored in entry, keeping its place in the insertion order. The caller must hold the write
+// lock.
+func (s *MemoryStore) replace(entry *memoryEntry, person *Person, revision Revision) {
+	entry.revision = revision
+	s.unindex(entry)
+	s.index(entry, person)
+}
+
+// index stores a copy of person in entry and adds it to the secondary indexes. The caller must hold the write lock.
+func (s *MemoryStore) index(entry *memoryEntry, person *Person) {
+	entry.person = person.clone()
+	entry.nameKey = nameKey(person.FirstName, person.LastName)
+	entry.phoneNumber = normalizedPhoneNumber(person.PhoneNumber)
+
+	addToIndex(s.byName, entry.nameKey, entry)
+	addToIndex(s.byPhone, entry.phoneNumber, entry)
+}
+
+// unindex removes entry from the secondary indexes. The caller must hold the write lock.
+func (s *MemoryStore) unindex(entry *memoryEntry) {
+	removeFromIndex(s.byName, entry.nameKey, entry)
+	removeFromIndex(s.byPhone, entry.phoneNumber, entry)
+}
+
+func addToIndex(index map[string]entrySet, key string, entry *memoryEntry) {
+	set, ok := index[key]
+	if !ok {
+		set = make(entrySet)
+		index[key] = set
+	}
+	set[entry.person.ID] = entry
+}
+
+func removeFromIndex(index map[string]entrySet, key string, entry *memoryEntry) {
+	set := index[key]
+	delete(set, entry.person.ID)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(SamplePeople()...)
+
+	t.Run("All", func(t *testing.T) {
+		results, err := store.All()
+
+		assert.Nil(t, err)
+		assert.Equal(t, AllPeople(), results)
+
+		count, err := store.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 5, count)
+	})
+	t.Run("Returns Copies", func(t *testing.T) {
+		person, err := store.FindByID(uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81")))
+		assert.Nil(t, err)
+		person.FirstName = "Changed"
+
+		person, err = store.FindByID(uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81")))
+		assert.Nil(t, err)
+		assert.Equal(t, "John", person.FirstName)
+	})
+	t.Run("Create", func(t *testing.T) {
+		person := &Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"}
+		_, err := store.Create(person)
+
+		assert.Nil(t, err)
+		assert.NotEqual(t, uuid.Nil, person.ID)
+
+		found, err := store.FindByID(person.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, person, found)
+	})
+	t.Run("Create Existing", func(t *testing.T) {
+		_, err := store.Create(&Person{ID: uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81"))})
+
+		assert.ErrorIs(t, err, ErrPersonExists)
+	})
+	t.Run("Update", func(t *testing.T) {
+		person, err := store.FindByID(uuid.Must(uuid.FromString("5b81b629-9026-450d-8e46-da4f8c7bd513")))
+		assert.Nil(t, err)
+
+		person.LastName = "Smith"
+		assert.Nil(t, store.Update(person))
+
+		results, err := store.Search(NameQuery("Jane", "Smith"))
+		assert.Nil(t, err)
+		assert.Len(t, results, 1)
+	})
+	t.Run("Update Missing", func(t *testing.T) {
+		err := store.Update(&Person{ID: uuid.NewV4()})
+
+		assert.ErrorIs(t, err, ErrPersonNotFound)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		id := uuid.Must(uuid.FromString("df12ce76-767b-4bf0-bccb-816745df9e70"))
+		assert.Nil(t, store.Delete(id))
+
+		_, err := store.FindByID(id)
+		assert.ErrorIs(t, err, ErrPersonNotFound)
+		assert.ErrorIs(t, store.Delete(id), ErrPersonNotFound)
+
+		results, err := store.Search(PhoneNumberQuery("+44 7700 900077"))
+		assert.Nil(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "Jenny", results[0].FirstName)
+	})
+}
+
+func TestMemoryStore_Each(t *testing.T) {
+	t.Run("Pages", func(t *testing.T) {
+		store := NewMemoryStore(SamplePeople()...)
+		var results []*Person
+		pages := 0
+		assert.Nil(t, store.Each(2, func(people []*Person) error {
+			assert.LessOrEqual(t, len(people), 2)
+			results = append(results, people...)
+			pages++
+			return nil
+		}))
+		assert.Equal(t, AllPeople(), results)
+		assert.Equal(t, 3, pages)
+	})
+	t.Run("Changed Between Pages", func(t *testing.T) {
+		store := NewMemoryStore(SamplePeople()...)
+		var results []*Person
+		assert.Nil(t, store.Each(2, func(people []*Person) error {
+			if len(results) == 0 {
+				// The store isn't locked while a page is handled. The last person of the page is deleted so the next
+				// page has to be found without it.
+				assert.Nil(t, store.Delete(people[1].ID))
+				_, err := store.Create(&Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"})
+				assert.Nil(t, err)
+			}
+			results = append(results, people...)
+			return nil
+		}))
+
+		all := AllPeople()
+		assert.Len(t, results, len(all)+1)
+		assert.Equal(t, all, results[:len(all)])
+		assert.Equal(t, "Jack", results[len(all)].FirstName)
+	})
+	t.Run("Stops At Error", func(t *testing.T) {
+		store := NewMemoryStore(SamplePeople()...)
+		stop := fmt.Errorf("stop")
+		calls := 0
+		assert.Equal(t, stop, store.Each(1, func([]*Person) error {
+			calls++
+			return stop
+		}))
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestMemoryStore_Indexes(t *testing.T) {
+	store := NewMemoryStore(SamplePeople()...)
+
+	t.Run("Concurrent Writes", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					person := &Person{FirstName: "Worker", LastName: fmt.Sprint(i), PhoneNumber: fmt.Sprintf("+1 (800) 555-%04d", j)}
+					_, err := store.Create(person)
+					assert.Nil(t, err)
+					_, _ = store.Search(PhoneNumberQuery(person.PhoneNumber))
+
+					person.LastName = "Updated"
+					assert.Nil(t, store.Update(person))
+					if j%2 == 0 {
+						assert.Nil(t, store.Delete(person.ID))
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assertIndexesConsistent(t, store)
+		results, err := store.Search(NameQuery("Worker", "Updated"))
+		assert.Nil(t, err)
+		assert.Len(t, results, 400)
+		results, err = store.Search(PhoneNumberQuery("+1 (800) 555-0001"))
+		assert.Nil(t, err)
+		assert.Len(t, results, 8)
+	})
+	t.Run("Indexed Matches Scan", func(t *testing.T) {
+		queries := []Query{
+			NameQuery("John", "Doe"),
+			NameQuery("john", "doe"),
+			PhoneNumberQuery("+44 7700 900077"),
+			{Filters: []Filter{{Field: FieldFirstName, Value: "jenny", CaseInsensitive: true}, {Field: FieldLastName, Value: "Smith"}}},
+			{Filters: append(NameQuery("Brian", "Smith").Filters, PhoneNumberQuery("+447700900077").Filters...)},
+		}
+		for _, query := range queries {
+			indexed, err := store.Search(query)
+			assert.Nil(t, err)
+			assert.Equal(t, store.scan(query), indexed)
+		}
+	})
+}
+
+// assertIndexesConsistent checks that every secondary index entry points at a stored person with that key and that
+// every stored person is indexed
+func assertIndexesConsistent(t *testing.T, store *MemoryStore) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	assert.Equal(t, len(store.byID), store.order.Len())
+	indexed := 0
+	for key, set := range store.byName {
+		for id, entry := range set {
+			assert.Equal(t, store.byID[id], entry)
+			assert.Equal(t, nameKey(entry.person.FirstName, entry.person.LastName), key)
+			indexed++
+		}
+	}
+	assert.Equal(t, len(store.byID), indexed)
+
+	indexed = 0
+	for key, set := range store.byPhone {
+		for id, entry := range set {
+			assert.Equal(t, store.byID[id], entry)
+			assert.Equal(t, normalizedPhoneNumber(entry.person.PhoneNumber), key)
+			indexed++
+		}
+	}
+	assert.Equal(t, len(store.byID), indexed)
+}
+
+// benchmarkStore creates a store with size people, with every name and phone number shared by a handful of people
+func benchmarkStore(size int) *MemoryStore {
+	people := make([]*Person, 0, size)
+	for i := 0; i < size; i++ {
+		people = append(people, &Person{
+			ID:          uuid.NewV4(),
+			FirstName:   fmt.Sprintf("First%d", i/4),
+			LastName:    fmt.Sprintf("Last%d", i/4),
+			PhoneNumber: fmt.Sprintf("+1 (800) %07d", i/4),
+		})
+	}
+	return NewMemoryStore(people...)
+}
+
+func BenchmarkMemoryStore(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		store := benchmarkStore(size)
+		target, _ := store.All()
+		person := target[size/2]
+		nameQuery := NameQuery(person.FirstName, person.LastName)
+		phoneQuery := PhoneNumberQuery(person.PhoneNumber)
+
+		b.Run(fmt.Sprintf("FindByID/Indexed/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = store.FindByID(person.ID)
+			}
+		})
+		b.Run(fmt.Sprintf("FindByID/Scan/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for element := store.order.Front(); element != nil; element = element.Next() {
+					if uuid.Equal(element.Value.(*memoryEntry).person.ID, person.ID) {
+						break
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("SearchName/Indexed/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = store.Search(nameQuery)
+			}
+		})
+		b.Run(fmt.Sprintf("SearchName/Scan/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.scan(nameQuery)
+			}
+		})
+		b.Run(fmt.Sprintf("SearchPhone/Indexed/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = store.Search(phoneQuery)
+			}
+		})
+		b.Run(fmt.Sprintf("SearchPhone/Scan/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.scan(phoneQuery)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Revisions(t *testing.T) {
+	store := NewMemoryStore(SamplePeople()...)
+	now := time.Unix(1600000000, 0)
+	store.now = func() time.Time { return now }
+	id := uuid.Must(uuid.FromString("81eb745b-3aae-400b-959f-748fcafafd81"))
+
+	t.Run("Created At Version 1", func(t *testing.T) {
+		person := &Person{FirstName: "Jack", LastName: "Doe", PhoneNumber: "+1 (800) 555-1515"}
+		created, err := store.Create(person)
+		assert.Nil(t, err)
+		assert.Equal(t, Revision{Version: 1, UpdatedAt: now.UTC()}, created)
+
+		_, revision, err := store.FindRevisionByID(person.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created, revision)
+	})
+	t.Run("Update Increments Version", func(t *testing.T) {
+		person, revision, err := store.FindRevisionByID(id)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), revision.Version)
+
+		now = now.Add(time.Minute)
+		person.LastName = "Roe"
+		revision, err = store.UpdateRevision(person, revision.Version)
+		assert.Nil(t, err)
+		assert.Equal(t, Revision{Version: 2, UpdatedAt: now.UTC()}, revision)
+
+		assert.Nil(t, store.Update(person))
+		_, revision, _ = store.FindRevisionByID(id)
+		assert.Equal(t, uint64(3), revision.Version)
+	})
+	t.Run("Stale Revision", func(t *testing.T) {
+		person, _, _ := store.FindRevisionByID(id)
+		person.LastName = "Stale"
+
+		_, err := store.UpdateRevision(person, 2)
+		assert.ErrorIs(t, err, ErrRevisionMismatch)
+		assert.ErrorIs(t, store.DeleteRevision(id, 2), ErrRevisionMismatch)
+
+		found, _ := store.FindByID(id)
+		assert.Equal(t, "Roe", found.LastName)
+	})
+	t.Run("Delete Current Revision", func(t *testing.T) {
+		assert.Nil(t, store.DeleteRevision(id, 3))
+
+		_, _, err := store.FindRevisionByID(id)
+		assert.ErrorIs(t, err, ErrPersonNotFound)
+		_, err = store.UpdateRevision(&Person{ID: id}, AnyRevision)
+		assert.ErrorIs(t, err, ErrPersonNotFound)
+	})
+	t.Run("Recreated Continues Version", func(t *testing.T) {
+		// The deleted person's revisions must never identify the new one
+		revision, err := store.Create(&Person{ID: id, FirstName: "John", LastName: "Poe", PhoneNumber: "+1 (800) 555-1313"})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(4), revision.Version)
+	})
+}
@@ -1,18 +1,38 @@
 package models
 
 import (
-	"errors"
-	"fmt"
+	"encoding/xml"
 
 	"github.com/satori/go.uuid"
 )
 
 // Person defines a simple representation of a person
 type Person struct {
-	ID          uuid.UUID `json:"id"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	PhoneNumber string    `json:"phone_number"`
+	XMLName     xml.Name  `json:"-" xml:"person"`
+	ID          uuid.UUID `json:"id" xml:"id"`
+	FirstName   string    `json:"first_name" xml:"first_name"`
+	LastName    string    `json:"last_name" xml:"last_name"`
+	PhoneNumber string    `json:"phone_number" xml:"phone_number"`
+}
+
+// PersonPatch holds a partial update to a Person, nil fields are left unchanged
+type PersonPatch struct {
+	FirstName   *string `json:"first_name"`
+	LastName    *string `json:"last_name"`
+	PhoneNumber *string `json:"phone_number"`
+}
+
+// Apply copies every provided field onto person
+func (p *PersonPatch) Apply(person *Person) {
+	if p.FirstName != nil {
+		person.FirstName = *p.FirstName
+	}
+	if p.LastName != nil {
+		person.LastName = *p.LastName
+	}
+	if p.PhoneNumber != nil {
+		person.PhoneNumber = *p.PhoneNumber
+	}
 }
 
 // people is the data source for the People RESTful service.
@@ -51,44 +71,44 @@ var people = []*Person{
 	},
 }
 
+// sampleStore serves the package level finders from the sample data set.
+var sampleStore = NewMemoryStore(people...)
+
+// SamplePeople returns copies of the sample data set, useful for seeding a PersonStore.
+func SamplePeople() []*Person {
+	result := make([]*Person, 0, len(people))
+	for _, person := range people {
+		result = append(result, person.clone())
+	}
+	return result
+}
+
 // AllPeople returns all people in `people`.
 func AllPeople() []*Person {
-	return people
+	result, _ := sampleStore.All()
+	return result
 }
 
 // FindPersonByID searches for people in `people` the by their ID.
 func FindPersonByID(id uuid.UUID) (*Person, error) {
-	for _, person := range people {
-		if person.ID == id {
-			return person, nil
-		}
-	}
-
-	return nil, errors.New(fmt.Sprintf("user ID %s not found", id.String()))
+	return sampleStore.FindByID(id)
 }
 
 // FindPeopleByName performs a case-sensitive search for people in `people` by first and last name.
 func FindPeopleByName(firstName, lastName string) []*Person {
-	result := make([]*Person, 0)
-
-	for _, person := range people {
-		if person.FirstName == firstName && person.LastName == lastName {
-			result = append(result, person)
-		}
-	}
-
+	result, _ := sampleStore.Search(NameQuery(firstName, lastName))
 	return result
 }
 
-// FindPeopleByPhoneNumber searches for people in `people` by phone number.
+// FindPeopleByPhoneNumber searches for people in `people` by phone number, in any format that normalizes to the same
+// E.164 number.
 func FindPeopleByPhoneNumber(phoneNumber string) []*Person {
-	result := make([]*Person, 0)
-
-	for _, person := range people {
-		if person.PhoneNumber == phoneNumber {
-			result = append(result, person)
-		}
-	}
-
+	result, _ := sampleStore.Search(PhoneNumberQuery(phoneNumber))
 	return result
 }
+
+// clone returns a copy of the person so stored data can't be modified by callers
+func (p *Person) clone() *Person {
+	person := *p
+	return &person
+}
@@ -73,6 +73,18 @@ func TestFindPeopleByPhoneNumber(t *testing.T) {
 		assert.Equal(t, "John", results[0].FirstName)
 		assert.Equal(t, "Doe", results[0].LastName)
 	})
+	t.Run("Found E.164", func(t *testing.T) {
+		results := FindPeopleByPhoneNumber("+18005551212")
+
+		assert.Len(t, results, 1)
+		assert.Equal(t, "+1 (800) 555-1212", results[0].PhoneNumber)
+	})
+	t.Run("Found Different Format", func(t *testing.T) {
+		results := FindPeopleByPhoneNumber("1-800-555-1212")
+
+		assert.Len(t, results, 1)
+		assert.Equal(t, "+1 (800) 555-1212", results[0].PhoneNumber)
+	})
 	t.Run("Not Found", func(t *testing.T) {
 		results := FindPeopleByPhoneNumber("+1 (800) 555-1234")
 		assert.Len(t, results, 0)
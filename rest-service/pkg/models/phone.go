@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCountryCode is the calling code assumed for phone numbers written without an international prefix
+var DefaultCountryCode = "1"
+
+const (
+	// minPhoneDigits and maxPhoneDigits bound the digits of a normalized number including the country code, E.164
+	// allows at most 15
+	minPhoneDigits = 7
+	maxPhoneDigits = 15
+)
+
+var (
+	// ErrInvalidPhoneCharacters is returned when a phone number contains something other than digits and punctuation
+	ErrInvalidPhoneCharacters = errors.New("phone number may only contain digits, a leading plus, spaces, hyphens, periods and parentheses")
+	// ErrInvalidPhoneLength is returned when a phone number has too few or too many digits to be valid
+	ErrInvalidPhoneLength = fmt.Errorf("phone number must contain between %d and %d digits including the country code", minPhoneDigits, maxPhoneDigits)
+)
+
+// NormalizePhoneNumber converts a phone number to E.164, e.g. "+1 (800) 555-1212" becomes "+18005551212".
+//
+// Punctuation is stripped, the international prefixes "00" and "011" are treated like "+", and the "(0)" trunk prefix
+// written in some international numbers is dropped. Numbers without an international prefix are assumed to be in
+// DefaultCountryCode, with a leading national trunk prefix removed.
+func NormalizePhoneNumber(phoneNumber string) (string, error) {
+	phoneNumber = strings.TrimSpace(phoneNumber)
+	international := strings.HasPrefix(phoneNumber, "+")
+	if international {
+		phoneNumber = strings.Replace(phoneNumber[1:], "(0)", "", 1)
+	}
+
+	digits := make([]byte, 0, len(phoneNumber))
+	for i := 0; i < len(phoneNumber); i++ {
+		switch c := phoneNumber[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", ErrInvalidPhoneCharacters
+		}
+	}
+
+	number := string(digits)
+	if !international {
+		switch {
+		case strings.HasPrefix(number, "00"):
+			number = number[2:]
+		case strings.HasPrefix(number, "011"):
+			number = number[3:]
+		case DefaultCountryCode == "1" && len(number) == 11 && strings.HasPrefix(number, "1"):
+			// North American numbers are often written with the country code but without the plus
+			number = number[1:]
+			fallthrough
+		default:
+			number = DefaultCountryCode + strings.TrimPrefix(number, "0")
+		}
+	}
+
+	if len(number) < minPhoneDigits || len(number) > maxPhoneDigits || number[0] == '0' {
+		return "", ErrInvalidPhoneLength
+	}
+	return "+" + number, nil
+}
+
+// MaskPhoneNumber hides the subscriber digits of a phone number, keeping its formatting, the leading digit groups that
+// make up at most half of the number, usually the country and area code, and the last two digits. For example
+// "+44 7700 900077" becomes "+44 7700 ****77" and "+1 (800) 555-1212" becomes "+1 (800) ***-**12".
+func MaskPhoneNumber(phoneNumber string) string {
+	digits := 0
+	groups := make([]int, 0, 4)
+	inGroup := false
+	for i := 0; i < len(phoneNumber); i++ {
+		isDigit := phoneNumber[i] >= '0' && phoneNumber[i] <= '9'
+		if isDigit {
+			digits++
+			if !inGroup {
+				groups = append(groups, 0)
+			}
+			groups[len(groups)-1]++
+		}
+		inGroup = isDigit
+	}
+
+	// Numbers too short to be valid are masked entirely
+	keepLeading, keepTrailing := 0, 0
+	if digits >= minPhoneDigits {
+		keepTrailing = 2
+		for _, group := range groups {
+			if keepLeading+group > digits/2 {
+				break
+			}
+			keepLeading += group
+		}
+		if keepLeading == 0 {
+			keepLeading = digits / 2
+		}
+	}
+
+	masked := []byte(phoneNumber)
+	seen := 0
+	for i, c := range masked {
+		if c < '0' || c > '9' {
+			// Anything that isn't a digit or formatting could itself be a number, e.g. letters in "1-800-FLOWERS"
+			if !strings.ContainsRune(" +-.()", rune(c)) {
+				masked[i] = '*'
+			}
+			continue
+		}
+		if seen >= keepLeading && seen < digits-keepTrailing {
+			masked[i] = '*'
+		}
+		seen++
+	}
+	return string(masked)
+}
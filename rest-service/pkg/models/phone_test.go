@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{input: "+1 (800) 555-1212", expected: "+18005551212"},
+		{input: "+18005551212", expected: "+18005551212"},
+		{input: "1-800-555-1212", expected: "+18005551212"},
+		{input: "(800) 555.1212", expected: "+18005551212"},
+		{input: " 18005551212 ", expected: "+18005551212"},
+		{input: "+44 7700 900077", expected: "+447700900077"},
+		{input: "+44 (0)7700 900077", expected: "+447700900077"},
+		{input: "0044 7700 900077", expected: "+447700900077"},
+		{input: "011 44 7700 900077", expected: "+447700900077"},
+		{input: "+1 800 555 1212 ext 5", err: ErrInvalidPhoneCharacters},
+		{input: "+1 (800) 555-1212+", err: ErrInvalidPhoneCharacters},
+		{input: "12345", err: ErrInvalidPhoneLength},
+		{input: "+1234567890123456", err: ErrInvalidPhoneLength},
+		{input: "+0 800 555 1212", err: ErrInvalidPhoneLength},
+		{input: "", err: ErrInvalidPhoneLength},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := NormalizePhoneNumber(test.input)
+
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+
+	t.Run("Default Country Code", func(t *testing.T) {
+		defer func(code string) { DefaultCountryCode = code }(DefaultCountryCode)
+		DefaultCountryCode = "44"
+
+		result, err := NormalizePhoneNumber("07700 900077")
+		assert.Nil(t, err)
+		assert.Equal(t, "+447700900077", result)
+	})
+}
+
+func TestMaskPhoneNumber(t *testing.T) {
+	tests := map[string]string{
+		"+44 7700 900077":    "+44 7700 ****77",
+		"+1 (800) 555-1212":  "+1 (800) ***-**12",
+		"+1 800 5551212":     "+1 800 *****12",
+		"+447700900077":      "+447700****77",
+		"011 44 7700 900077": "011 44 **** ****77",
+		"12345":              "*****",
+		"1-800-FLOWERS":      "*-***-*******",
+		"":                   "",
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, MaskPhoneNumber(input), input)
+	}
+}
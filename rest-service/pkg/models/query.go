@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields that people can be filtered by
+const (
+	FieldFirstName   = "first_name"
+	FieldLastName    = "last_name"
+	FieldPhoneNumber = "phone_number"
+)
+
+// Filter matches a single field of a person against a value
+type Filter struct {
+	Field string
+	// Value is compared against the field, for phone numbers it is already normalized
+	Value string
+	// Prefix matches any field that starts with Value instead of equalling it
+	Prefix bool
+	// CaseInsensitive ignores case when comparing names
+	CaseInsensitive bool
+}
+
+// NewFilter creates a filter for field. A trailing "*" in value matches any field starting with the rest of value, e.g.
+// "smi*". Phone numbers are always compared in their E.164 form so case sensitivity does not apply to them.
+func NewFilter(field, value string, caseInsensitive bool) (Filter, error) {
+	filter := Filter{Field: field, Value: value, CaseInsensitive: caseInsensitive}
+	if strings.HasSuffix(value, "*") {
+		filter.Value = strings.TrimSuffix(value, "*")
+		filter.Prefix = true
+	}
+	if len(filter.Value) == 0 {
+		return filter, fmt.Errorf("%s must not be empty", field)
+	}
+
+	switch field {
+	case FieldFirstName, FieldLastName:
+		if filter.CaseInsensitive {
+			filter.Value = strings.ToLower(filter.Value)
+		}
+	case FieldPhoneNumber:
+		filter.CaseInsensitive = false
+		if filter.Prefix {
+			// Partial numbers can't be normalized, compare the digits including the country code instead
+			filter.Value = phoneDigits(filter.Value)
+		} else {
+			filter.Value = normalizedPhoneNumber(filter.Value)
+		}
+	default:
+		return filter, fmt.Errorf("unable to filter by unknown field %q", field)
+	}
+	return filter, nil
+}
+
+// Query is a set of filters that must all match, an empty query matches everyone
+type Query struct {
+	Filters []Filter
+}
+
+// NameQuery returns a case-sensitive query for an exact first and last name
+func NameQuery(firstName, lastName string) Query {
+	return Query{Filters: []Filter{
+		{Field: FieldFirstName, Value: firstName},
+		{Field: FieldLastName, Value: lastName},
+	}}
+}
+
+// PhoneNumberQuery returns a query for people with the same E.164 phone number
+func PhoneNumberQuery(phoneNumber string) Query {
+	return Query{Filters: []Filter{{Field: FieldPhoneNumber, Value: normalizedPhoneNumber(phoneNumber)}}}
+}
+
+// Matches reports whether the person matches every filter in the query
+func (q Query) Matches(person *Person) bool {
+	return q.matches(person, normalizedPhoneNumber(person.PhoneNumber))
+}
+
+// matches is Matches with the person's phone number already normalized
+func (q Query) matches(person *Person, phoneNumber string) bool {
+	for _, filter := range q.Filters {
+		var value string
+		switch filter.Field {
+		case FieldFirstName:
+			value = person.FirstName
+		case FieldLastName:
+			value = person.LastName
+		case FieldPhoneNumber:
+			value = phoneNumber
+			if filter.Prefix {
+				value = phoneDigits(phoneNumber)
+			}
+		default:
+			return false
+		}
+
+		if !filter.match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) match(value string) bool {
+	if f.CaseInsensitive {
+		value = strings.ToLower(value)
+	}
+	if f.Prefix {
+		return strings.HasPrefix(value, f.Value)
+	}
+	return value == f.Value
+}
+
+// phoneDigits strips everything but the digits from a phone number
+func phoneDigits(phoneNumber string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phoneNumber)
+}
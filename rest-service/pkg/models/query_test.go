@@ -0,0 +1,70 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewFilter(t *testing.T) {
+	t.Run("Prefix", func(t *testing.T) {
+		filter, err := NewFilter(FieldLastName, "Smi*", false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Filter{Field: FieldLastName, Value: "Smi", Prefix: true}, filter)
+	})
+	t.Run("Case Insensitive", func(t *testing.T) {
+		filter, err := NewFilter(FieldFirstName, "JOHN", true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Filter{Field: FieldFirstName, Value: "john", CaseInsensitive: true}, filter)
+	})
+	t.Run("Phone Number Normalized", func(t *testing.T) {
+		filter, err := NewFilter(FieldPhoneNumber, "+1 (800) 555-1212", true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Filter{Field: FieldPhoneNumber, Value: "+18005551212"}, filter)
+	})
+	t.Run("Phone Number Prefix", func(t *testing.T) {
+		filter, err := NewFilter(FieldPhoneNumber, "+44 7700*", false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, Filter{Field: FieldPhoneNumber, Value: "447700", Prefix: true}, filter)
+	})
+	t.Run("Empty", func(t *testing.T) {
+		_, err := NewFilter(FieldLastName, "*", false)
+
+		assert.NotNil(t, err)
+	})
+	t.Run("Unknown Field", func(t *testing.T) {
+		_, err := NewFilter("age", "42", false)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestQuery_Matches(t *testing.T) {
+	search := func(filters ...Filter) []string {
+		names := make([]string, 0)
+		for _, person := range SamplePeople() {
+			if (Query{Filters: filters}).Matches(person) {
+				names = append(names, person.FirstName+" "+person.LastName)
+			}
+		}
+		return names
+	}
+	filter := func(field, value string, caseInsensitive bool) Filter {
+		result, err := NewFilter(field, value, caseInsensitive)
+		assert.Nil(t, err)
+		return result
+	}
+
+	assert.Len(t, search(), 5)
+	assert.Equal(t, []string{"Brian Smith", "Jenny Smith"}, search(filter(FieldLastName, "Smith", false)))
+	assert.Equal(t, []string{"Brian Smith", "Jenny Smith"}, search(filter(FieldLastName, "smi*", true)))
+	assert.Len(t, search(filter(FieldLastName, "smi*", false)), 0)
+	assert.Equal(t, []string{"John Doe", "John Doe"}, search(filter(FieldFirstName, "john", true)))
+	assert.Equal(t, []string{"Jenny Smith"}, search(filter(FieldFirstName, "J*", false), filter(FieldFirstName, "je*", true)))
+	assert.Equal(t, []string{"John Doe"}, search(filter(FieldFirstName, "John", false), filter(FieldPhoneNumber, "18005551414", false)))
+	assert.Equal(t, []string{"Brian Smith", "Jenny Smith"}, search(filter(FieldPhoneNumber, "+44*", false)))
+	assert.Len(t, search(filter(FieldFirstName, "Jane", false), filter(FieldLastName, "Smith", false)), 0)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortableFields maps the JSON field names people can be sorted by to a comparison of that field
+var sortableFields = map[string]func(a, b *Person) int{
+	"id":           func(a, b *Person) int { return strings.Compare(a.ID.String(), b.ID.String()) },
+	"first_name":   func(a, b *Person) int { return strings.Compare(a.FirstName, b.FirstName) },
+	"last_name":    func(a, b *Person) int { return strings.Compare(a.LastName, b.LastName) },
+	"phone_number": func(a, b *Person) int { return strings.Compare(a.PhoneNumber, b.PhoneNumber) },
+}
+
+// SortField is a single field to order people by
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort parses a comma separated list of fields to sort by, e.g. "last_name,-first_name". A leading "-" sorts
+// that field in descending order.
+func ParseSort(spec string) ([]SortField, error) {
+	fields := make([]SortField, 0)
+	if len(spec) == 0 {
+		return fields, nil
+	}
+
+	for _, name := range strings.Split(spec, ",") {
+		field := SortField{Field: strings.TrimSpace(name)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Field = field.Field[1:]
+			field.Descending = true
+		}
+		if _, ok := sortableFields[field.Field]; !ok {
+			return nil, fmt.Errorf("unable to sort by unknown field %q", field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// SortPeople sorts people in place by the fields in order. The sort is stable so people that compare equal keep their
+// original order.
+func SortPeople(people []*Person, fields []SortField) {
+	if len(fields) == 0 {
+		return
+	}
+
+	sort.SliceStable(people, func(i, j int) bool {
+		for _, field := range fields {
+			result := sortableFields[field.Field](people[i], people[j])
+			if field.Descending {
+				result = -result
+			}
+			if result != 0 {
+				return result < 0
+			}
+		}
+		return false
+	})
+}
+
+// Paginate returns at most limit people starting at offset. An offset past the end returns an empty slice.
+func Paginate(people []*Person, limit, offset int) []*Person {
+	if offset >= len(people) {
+		return make([]*Person, 0)
+	}
+
+	end := offset + limit
+	if end > len(people) {
+		end = len(people)
+	}
+	return people[offset:end]
+}
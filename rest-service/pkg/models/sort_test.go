@@ -0,0 +1,58 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		fields, err := ParseSort("")
+
+		assert.Nil(t, err)
+		assert.Len(t, fields, 0)
+	})
+	t.Run("Multiple", func(t *testing.T) {
+		fields, err := ParseSort("last_name, -first_name")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []SortField{{Field: "last_name"}, {Field: "first_name", Descending: true}}, fields)
+	})
+	t.Run("Unknown Field", func(t *testing.T) {
+		_, err := ParseSort("last_name,age")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSortPeople(t *testing.T) {
+	t.Run("Ascending And Descending", func(t *testing.T) {
+		people := SamplePeople()
+		fields, _ := ParseSort("last_name,-first_name")
+		SortPeople(people, fields)
+
+		names := make([]string, 0, len(people))
+		for _, person := range people {
+			names = append(names, person.FirstName+" "+person.LastName)
+		}
+		assert.Equal(t, []string{"John Doe", "John Doe", "Jane Doe", "Jenny Smith", "Brian Smith"}, names)
+		// Stable, so the two John Does keep their original order
+		assert.Equal(t, "+1 (800) 555-1212", people[0].PhoneNumber)
+		assert.Equal(t, "+1 (800) 555-1414", people[1].PhoneNumber)
+	})
+	t.Run("No Fields", func(t *testing.T) {
+		people := SamplePeople()
+		SortPeople(people, nil)
+
+		assert.Equal(t, SamplePeople(), people)
+	})
+}
+
+func TestPaginate(t *testing.T) {
+	people := SamplePeople()
+
+	assert.Equal(t, people[:2], Paginate(people, 2, 0))
+	assert.Equal(t, people[4:], Paginate(people, 2, 4))
+	assert.Equal(t, people, Paginate(people, 100, 0))
+	assert.Len(t, Paginate(people, 2, 5), 0)
+}
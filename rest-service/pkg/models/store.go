@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+var (
+	// ErrPersonNotFound is returned by a PersonStore when the requested person does not exist.
+	ErrPersonNotFound = errors.New("person not found")
+	// ErrPersonExists is returned by a PersonStore when creating a person with an ID that is already in use.
+	ErrPersonExists = errors.New("person already exists")
+	// ErrRevisionMismatch is returned by a PersonStore when a conditional change was made against an outdated revision.
+	ErrRevisionMismatch = errors.New("person has been modified")
+)
+
+// AnyRevision makes a conditional change apply to whichever revision of the person is current.
+const AnyRevision uint64 = 0
+
+// Revision identifies a version of a stored person. Version starts at 1 when the person is created and increases by
+// one with every update. A person created with the ID of a deleted person continues from the deleted person's version,
+// so a version never identifies two different people.
+type Revision struct {
+	Version   uint64
+	UpdatedAt time.Time
+}
+
+// PersonStore is the storage backend for people.
+//
+// Implementations must be safe to use from multiple goroutines, and must return copies of the stored people so that
+// callers are free to modify the results without affecting the store.
+type PersonStore interface {
+	// All returns every person in the store in insertion order.
+	All() ([]*Person, error)
+	// Each calls fn with every person in insertion order, size people at a time, stopping at and returning the first
+	// error fn returns. Only copying each page holds the store's lock, so fn may be slow or change the store. People
+	// changed between pages are seen as they are when their page is copied.
+	Each(size int, fn func([]*Person) error) error
+	// Count returns the number of people in the store.
+	Count() (int, error)
+	// FindByID returns the person with the given ID or ErrPersonNotFound.
+	FindByID(id uuid.UUID) (*Person, error)
+	// FindRevisionByID returns the person with the given ID along with its current revision, or ErrPersonNotFound.
+	FindRevisionByID(id uuid.UUID) (*Person, Revision, error)
+	// Search returns every person matching the query in insertion order.
+	Search(query Query) ([]*Person, error)
+
+	// Create adds a new person to the store. If the person's ID is nil a new one is generated and set on person.
+	// Returns the new revision, or ErrPersonExists if the ID is already in use.
+	Create(person *Person) (Revision, error)
+	// Update replaces the stored person with the same ID, or returns ErrPersonNotFound.
+	Update(person *Person) error
+	// Delete removes the person with the given ID, or returns ErrPersonNotFound.
+	Delete(id uuid.UUID) error
+	// UpdateRevision is Update that only applies if the stored person's version is version, otherwise it returns
+	// ErrRevisionMismatch. Returns the new revision.
+	UpdateRevision(person *Person, version uint64) (Revision, error)
+	// DeleteRevision is Delete that only applies if the stored person's version is version, otherwise it returns
+	// ErrRevisionMismatch.
+	DeleteRevision(id uuid.UUID, version uint64) error
+}
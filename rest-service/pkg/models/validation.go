@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Validation error codes reported in ErrorDetail.Code
+const (
+	CodeRequired          = "required"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeInvalidFormat     = "invalid_format"
+)
+
+// MaxNameLength is the longest first or last name allowed, in characters
+const MaxNameLength = 100
+
+// ValidationError is returned by Validate with every field that failed validation
+type ValidationError struct {
+	Details []ErrorDetail
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Details))
+	for _, detail := range e.Details {
+		fields = append(fields, fmt.Sprintf("%s: %s", detail.Field, detail.Message))
+	}
+	return "validation failed, " + strings.Join(fields, "; ")
+}
+
+// add records a failed field
+func (e *ValidationError) add(field, code, message string) {
+	e.Details = append(e.Details, ErrorDetail{Field: field, Code: code, Message: message})
+}
+
+// Validate checks every field of the person, returning a *ValidationError listing all failures or nil if valid.
+func (p *Person) Validate() error {
+	result := &ValidationError{}
+	validateName(result, "first_name", p.FirstName)
+	validateName(result, "last_name", p.LastName)
+	validatePhoneNumber(result, "phone_number", p.PhoneNumber)
+
+	if len(result.Details) > 0 {
+		return result
+	}
+	return nil
+}
+
+func validateName(result *ValidationError, field, name string) {
+	if len(strings.TrimSpace(name)) == 0 {
+		result.add(field, CodeRequired, "must not be empty")
+		return
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		result.add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && r != ' ' && r != '-' && r != '\'' && r != '.' {
+			result.add(field, CodeInvalidCharacters, "may only contain letters, spaces, hyphens, apostrophes and periods")
+			return
+		}
+	}
+}
+
+func validatePhoneNumber(result *ValidationError, field, phoneNumber string) {
+	if len(strings.TrimSpace(phoneNumber)) == 0 {
+		result.add(field, CodeRequired, "must not be empty")
+		return
+	}
+
+	switch _, err := NormalizePhoneNumber(phoneNumber); {
+	case errors.Is(err, ErrInvalidPhoneCharacters):
+		result.add(field, CodeInvalidCharacters, "may only contain digits, a leading plus, spaces, hyphens, periods and parentheses")
+	case err != nil:
+		result.add(field, CodeInvalidFormat, fmt.Sprintf("must contain between %d and %d digits including the country code", minPhoneDigits, maxPhoneDigits))
+	}
+}
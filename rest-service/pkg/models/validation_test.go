@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPerson_Validate(t *testing.T) {
+	t.Run("Sample People Valid", func(t *testing.T) {
+		for _, person := range SamplePeople() {
+			assert.Nil(t, person.Validate())
+		}
+	})
+	t.Run("Valid Names", func(t *testing.T) {
+		person := &Person{FirstName: "Mary-Jane", LastName: "O'Brien Jr.", PhoneNumber: "+353 1 234 5678"}
+		assert.Nil(t, person.Validate())
+
+		person = &Person{FirstName: "Zoë", LastName: "Åström", PhoneNumber: "020.7946.0018"}
+		assert.Nil(t, person.Validate())
+	})
+
+	tests := []struct {
+		name   string
+		person Person
+		fields map[string]string
+	}{
+		{
+			name:   "Empty",
+			person: Person{},
+			fields: map[string]string{"first_name": CodeRequired, "last_name": CodeRequired, "phone_number": CodeRequired},
+		},
+		{
+			name:   "Whitespace Names",
+			person: Person{FirstName: "  ", LastName: "\t", PhoneNumber: "+1 (800) 555-1212"},
+			fields: map[string]string{"first_name": CodeRequired, "last_name": CodeRequired},
+		},
+		{
+			name:   "Name Too Long",
+			person: Person{FirstName: strings.Repeat("a", MaxNameLength+1), LastName: "Doe", PhoneNumber: "+1 (800) 555-1212"},
+			fields: map[string]string{"first_name": CodeTooLong},
+		},
+		{
+			name:   "Name Invalid Characters",
+			person: Person{FirstName: "John", LastName: "<script>", PhoneNumber: "+1 (800) 555-1212"},
+			fields: map[string]string{"last_name": CodeInvalidCharacters},
+		},
+		{
+			name:   "Phone Invalid Characters",
+			person: Person{FirstName: "John", LastName: "Doe", PhoneNumber: "call me maybe"},
+			fields: map[string]string{"phone_number": CodeInvalidCharacters},
+		},
+		{
+			name:   "Phone Misplaced Plus",
+			person: Person{FirstName: "John", LastName: "Doe", PhoneNumber: "1+800 555 1212"},
+			fields: map[string]string{"phone_number": CodeInvalidCharacters},
+		},
+		{
+			name:   "Phone Too Short",
+			person: Person{FirstName: "John", LastName: "Doe", PhoneNumber: "+1 555"},
+			fields: map[string]string{"phone_number": CodeInvalidFormat},
+		},
+		{
+			name:   "Phone Too Long",
+			person: Person{FirstName: "John", LastName: "Doe", PhoneNumber: "+1 800 555 1212 1234 5"},
+			fields: map[string]string{"phone_number": CodeInvalidFormat},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.person.Validate()
+
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.Len(t, validationErr.Details, len(test.fields))
+			for _, detail := range validationErr.Details {
+				assert.Equal(t, test.fields[detail.Field], detail.Code, detail.Field)
+				assert.NotEmpty(t, detail.Message)
+			}
+			assert.Contains(t, err.Error(), validationErr.Details[0].Field)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type embedded struct {
+	Name string `json:"name"`
+}
+
+type example struct {
+	embedded
+	ID       [16]byte          `json:"-"`
+	Count    int               `json:"count"`
+	Ratio    float64           `json:"ratio,omitempty"`
+	Enabled  *bool             `json:"enabled"`
+	Created  time.Time         `json:"created"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Untagged string
+	private  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(&example{})
+
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"name", "count", "created", "Untagged"}, schema.Required)
+	assert.Equal(t, &Schema{Type: "string"}, schema.Properties["name"])
+	assert.Equal(t, &Schema{Type: "integer"}, schema.Properties["count"])
+	assert.Equal(t, &Schema{Type: "number"}, schema.Properties["ratio"])
+	assert.Equal(t, &Schema{Type: "boolean", Nullable: true}, schema.Properties["enabled"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["created"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, schema.Properties["tags"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, schema.Properties["labels"])
+	assert.NotContains(t, schema.Properties, "-")
+	assert.NotContains(t, schema.Properties, "private")
+}
+
+func TestDocument_AddOperation(t *testing.T) {
+	doc := New(Info{Title: "Test", Version: "1"})
+
+	assert.True(t, doc.AddOperation("GET", "/people/:id", &Operation{OperationID: "get"}))
+	assert.True(t, doc.AddOperation("DELETE", "/people/:id", &Operation{OperationID: "delete"}))
+	assert.False(t, doc.AddOperation("TRACE", "/people/:id", &Operation{}))
+
+	assert.Len(t, doc.Paths, 1)
+	assert.Equal(t, "get", doc.Paths["/people/{id}"].Get.OperationID)
+	assert.Equal(t, "delete", doc.Operation("DELETE", "/people/:id").OperationID)
+	assert.Nil(t, doc.Operation("PUT", "/people/:id"))
+	assert.Nil(t, doc.Operation("GET", "/people"))
+	assert.Equal(t, "/files/{path}", Path("/files/*path"))
+}
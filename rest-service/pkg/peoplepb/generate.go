@@ -0,0 +1,4 @@
+// Package peoplepb holds the protobuf messages and gRPC service generated from proto/people/v1/people.proto.
+package peoplepb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=module=github.com/stackpath/backend-developer-tests/rest-service/pkg/peoplepb --go-grpc_out=. --go-grpc_opt=module=github.com/stackpath/backend-developer-tests/rest-service/pkg/peoplepb people/v1/people.proto
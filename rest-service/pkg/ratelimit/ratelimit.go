@@ -0,0 +1,176 @@
+// Package ratelimit implements per-client token bucket rate limiting.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are discarded so idle clients don't use memory forever
+const sweepInterval = time.Minute
+
+// Limit allows Burst requests at once, refilling at Rate requests per second. The zero Limit is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether the limit allows every request
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// String formats the limit the way ParseLimit reads it
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%s/s:%d", strconv.FormatFloat(l.Rate, 'f', -1, 64), l.Burst)
+}
+
+var periods = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+// ParseLimit reads a limit written as requests per period with an optional burst, e.g. "10/s", "600/m" or "600/m:50".
+// Without a burst a whole period's worth of requests may be made at once. An empty value is unlimited.
+func ParseLimit(value string) (Limit, error) {
+	if len(value) == 0 {
+		return Limit{}, nil
+	}
+
+	rate, burst := value, ""
+	if i := strings.IndexByte(value, ':'); i >= 0 {
+		rate, burst = value[:i], value[i+1:]
+	}
+	parts := strings.Split(rate, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, must be requests per period such as 10/s", value)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, requests must be a positive number", value)
+	}
+	period, ok := periods[parts[1]]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, period must be s, m or h", value)
+	}
+
+	limit := Limit{Rate: float64(requests) / period.Seconds(), Burst: requests}
+	if len(burst) > 0 {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q, burst must be a positive number", value)
+		}
+	}
+	return limit, nil
+}
+
+// Result is the outcome of a request against a client's bucket
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size
+	Limit int
+	// Remaining is the number of requests that could be made immediately after this one
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed, zero if it would be allowed now
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter tracks a token bucket per client key. All functions are safe to call from multiple goroutines.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is replaced in tests
+	now func() time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Limit returns the limit applied to every client
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *Limiter) Allow(key string) Result {
+	return l.check(key, true)
+}
+
+// Peek reports whether key's bucket has a token available without taking it, Remaining counts the available token
+func (l *Limiter) Peek(key string) Result {
+	return l.check(key, false)
+}
+
+// check reports whether a token is available in key's bucket, taking it if take is set
+func (l *Limiter) check(key string, take bool) Result {
+	if l.limit.Unlimited() {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		if take {
+			l.buckets[key] = b
+		}
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	result := Result{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = l.duration(float64(l.limit.Burst) - b.tokens)
+	return result
+}
+
+// refill returns the tokens in b at now
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+}
+
+// duration returns how long it takes to refill tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.limit.Rate * float64(time.Second)))
+}
+
+// sweep discards buckets that have refilled since they were last used, as they are the same as a new bucket. The
+// caller must hold the lock.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Limit
+		err      bool
+	}{
+		{input: "", expected: Limit{}},
+		{input: "10/s", expected: Limit{Rate: 10, Burst: 10}},
+		{input: "600/m", expected: Limit{Rate: 10, Burst: 600}},
+		{input: "600/m:50", expected: Limit{Rate: 10, Burst: 50}},
+		{input: "3600/h", expected: Limit{Rate: 1, Burst: 3600}},
+		{input: "10", err: true},
+		{input: "10/d", err: true},
+		{input: "0/s", err: true},
+		{input: "ten/s", err: true},
+		{input: "10/s:0", err: true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("Parse %q", test.input), func(t *testing.T) {
+			limit, err := ParseLimit(test.input)
+
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, limit)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	limiter := New(Limit{Rate: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	t.Run("Burst", func(t *testing.T) {
+		for i := 2; i >= 0; i-- {
+			result := limiter.Allow("a")
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, i, result.Remaining)
+		}
+
+		result := limiter.Allow("a")
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+		assert.Equal(t, 1500*time.Millisecond, result.Reset)
+	})
+	t.Run("Clients Are Independent", func(t *testing.T) {
+		assert.True(t, limiter.Allow("b").Allowed)
+	})
+	t.Run("Refill", func(t *testing.T) {
+		now = now.Add(500 * time.Millisecond)
+		assert.True(t, limiter.Allow("a").Allowed)
+		assert.False(t, limiter.Allow("a").Allowed)
+
+		now = now.Add(time.Hour)
+		result := limiter.Allow("a")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+	})
+	t.Run("Sweep", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		limiter.Allow("c")
+
+		assert.Len(t, limiter.buckets, 1)
+	})
+	t.Run("Peek", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.True(t, limiter.Peek("d").Allowed)
+		}
+		assert.Equal(t, 3, limiter.Peek("d").Remaining)
+		assert.NotContains(t, limiter.buckets, "d")
+
+		for i := 0; i < 3; i++ {
+			limiter.Allow("d")
+		}
+		result := limiter.Peek("d")
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	})
+	t.Run("Unlimited", func(t *testing.T) {
+		limiter := New(Limit{})
+		for i := 0; i < 100; i++ {
+			assert.True(t, limiter.Allow("a").Allowed)
+		}
+	})
+}
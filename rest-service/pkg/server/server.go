@@ -0,0 +1,231 @@
+// Package server runs an http.Handler with configurable timeouts and graceful shutdown.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config holds the listen address and timeouts of a Server
+type Config struct {
+	// Addr is the TCP address to listen on, ":0" picks a free port
+	Addr string
+	// TLSCertFile and TLSKeyFile are the PEM certificate chain and private key to serve HTTPS with, HTTP is served when
+	// they are empty
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile is a PEM bundle of the CAs client certificates must be signed by, clients don't need a
+	// certificate when it is empty
+	TLSClientCAFile string
+	// TLSReloadInterval is how often the certificate files are checked for changes, zero never reloads them
+	TLSReloadInterval time.Duration
+	// ReadTimeout is the maximum duration for reading an entire request, including the body
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout is the maximum duration for reading the request headers
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout is the maximum duration before timing out writes of the response
+	WriteTimeout time.Duration
+	// IdleTimeout is the maximum time to wait for the next request on a keep-alive connection
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long in-flight requests are given to finish when shutting down
+	ShutdownTimeout time.Duration
+	// ShutdownDelay is how long to keep accepting requests after the shutdown hooks run, giving load balancers time to
+	// notice the instance is no longer ready before its listener closes
+	ShutdownDelay time.Duration
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":8080",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+		TLSReloadInterval: 10 * time.Second,
+	}
+}
+
+// Server An HTTP server that can be started and stopped, draining in-flight requests on shutdown
+type Server struct {
+	config     Config
+	httpServer *http.Server
+
+	mu       sync.Mutex
+	listener net.Listener
+	hooks    []func()
+	// done receives the result of serving once the server stops
+	done chan error
+	// stopReloading stops watching the TLS certificate files for changes
+	stopReloading chan struct{}
+	// tlsConfig and reloader are built by the first call to loadTLS, tlsLoaded records that it has been called
+	tlsConfig *tls.Config
+	reloader  *CertificateReloader
+	tlsLoaded bool
+}
+
+// New creates a Server for handler, it does not listen until Start is called
+func New(handler http.Handler, config Config) *Server {
+	return &Server{
+		config: config,
+		httpServer: &http.Server{
+			Addr:              config.Addr,
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+		done:          make(chan error, 1),
+		stopReloading: make(chan struct{}),
+	}
+}
+
+// Start listens on the configured address and serves requests in the background. It returns once the listener is
+// bound so Addr can be used straight away.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return errors.New("server already started")
+	}
+
+	tlsConfig, err := s.loadTLS()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s, %w", s.config.Addr, err)
+	}
+	s.listener = listener
+
+	serve := s.httpServer.Serve
+	if tlsConfig != nil {
+		s.httpServer.TLSConfig = tlsConfig
+		serve = func(listener net.Listener) error {
+			// The certificate comes from the TLS config rather than files
+			return s.httpServer.ServeTLS(listener, "", "")
+		}
+		if s.config.TLSReloadInterval > 0 {
+			go s.reloader.Watch(s.config.TLSReloadInterval, s.stopReloading)
+		}
+	}
+
+	go func() {
+		err := serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		s.done <- err
+		close(s.stopReloading)
+	}()
+	return nil
+}
+
+// TLSConfig returns the TLS configuration requests are served with, or nil when they are served over plain HTTP. It
+// is built once and shared so other listeners, such as gRPC's, serve the same reloaded certificate and require the
+// same client certificates.
+func (s *Server) TLSConfig() (*tls.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadTLS()
+}
+
+// loadTLS builds the TLS configuration on first use. The caller must hold s.mu.
+func (s *Server) loadTLS() (*tls.Config, error) {
+	if !s.tlsLoaded {
+		tlsConfig, reloader, err := s.config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig, s.reloader, s.tlsLoaded = tlsConfig, reloader, true
+	}
+	return s.tlsConfig, nil
+}
+
+// Addr returns the address the server is listening on, or an empty string if it hasn't started
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// RegisterOnShutdown registers a function to call as soon as shutdown begins, before the ShutdownDelay
+func (s *Server) RegisterOnShutdown(hook func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Shutdown runs the shutdown hooks, waits for the ShutdownDelay, then stops accepting connections and waits for
+// in-flight requests to finish, up to the ShutdownTimeout or until ctx is done. Connections still open after that are
+// closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.hooks = nil
+	s.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+
+	if s.config.ShutdownDelay > 0 {
+		select {
+		case <-time.After(s.config.ShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		_ = s.httpServer.Close()
+		return fmt.Errorf("error draining connections, %w", err)
+	}
+	return nil
+}
+
+// Wait blocks until the server stops serving, returning the error that stopped it if it wasn't shut down
+func (s *Server) Wait() error {
+	err := <-s.done
+	// Let later calls to Wait return the same result
+	s.done <- err
+	return err
+}
+
+// Run starts the server and blocks until ctx is done or the server fails, shutting down gracefully in the first case
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.Start(); err != nil {
+		return err
+	}
+	log.Printf("Listening on %s\n", s.Addr())
+
+	select {
+	case err := <-s.done:
+		s.done <- err
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down in %s, then waiting up to %s for in-flight requests\n", s.config.ShutdownDelay, s.config.ShutdownTimeout)
+	if err := s.Shutdown(context.Background()); err != nil {
+		return err
+	}
+	return s.Wait()
+}
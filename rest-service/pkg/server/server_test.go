@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Addr = "127.0.0.1:0"
+	config.ShutdownTimeout = time.Second
+	return config
+}
+
+func TestServer(t *testing.T) {
+	t.Run("Start And Shutdown", func(t *testing.T) {
+		srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}), testConfig())
+		assert.Equal(t, "", srv.Addr())
+		assert.Nil(t, srv.Start())
+		assert.NotNil(t, srv.Start())
+
+		resp, err := http.Get("http://" + srv.Addr())
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+
+		assert.Nil(t, srv.Shutdown(context.Background()))
+		assert.Nil(t, srv.Wait())
+		_, err = http.Get("http://" + srv.Addr())
+		assert.NotNil(t, err)
+	})
+	t.Run("Drains In-Flight Requests", func(t *testing.T) {
+		started := make(chan struct{})
+		srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte("finished"))
+		}), testConfig())
+		assert.Nil(t, srv.Start())
+
+		result := make(chan string)
+		go func() {
+			resp, err := http.Get("http://" + srv.Addr())
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			result <- string(body)
+		}()
+
+		<-started
+		assert.Nil(t, srv.Shutdown(context.Background()))
+		assert.Equal(t, "finished", <-result)
+	})
+	t.Run("Shutdown Deadline", func(t *testing.T) {
+		config := testConfig()
+		config.ShutdownTimeout = 50 * time.Millisecond
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}), config)
+		assert.Nil(t, srv.Start())
+
+		go func() {
+			resp, err := http.Get("http://" + srv.Addr())
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+
+		<-started
+		begin := time.Now()
+		assert.NotNil(t, srv.Shutdown(context.Background()))
+		assert.True(t, time.Since(begin) < time.Second)
+	})
+	t.Run("Run Until Cancelled", func(t *testing.T) {
+		srv := New(http.NotFoundHandler(), testConfig())
+		ctx, cancel := context.WithCancel(context.Background())
+
+		result := make(chan error)
+		go func() { result <- srv.Run(ctx) }()
+		for len(srv.Addr()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		cancel()
+		assert.Nil(t, <-result)
+	})
+	t.Run("Listen Error", func(t *testing.T) {
+		config := testConfig()
+		config.Addr = "not an address"
+
+		assert.NotNil(t, New(http.NotFoundHandler(), config).Start())
+	})
+}
+
+func TestServer_RegisterOnShutdown(t *testing.T) {
+	config := testConfig()
+	config.ShutdownDelay = 100 * time.Millisecond
+	srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}), config)
+	assert.Nil(t, srv.Start())
+
+	hookCalled := make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(hookCalled) })
+	shutdown := make(chan error)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+	<-hookCalled
+
+	// Requests are still served during the shutdown delay
+	resp, err := http.Get("http://" + srv.Addr())
+	assert.Nil(t, err)
+	if err == nil {
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Nil(t, <-shutdown)
+}
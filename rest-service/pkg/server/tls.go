@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves the certificate in a pair of PEM files, reloading it when either file changes so
+// certificates can be rotated without a restart
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	// versions are the modification time and size of the certificate and key files when they were last loaded
+	versions [2]fileVersion
+}
+
+type fileVersion struct {
+	modified time.Time
+	size     int64
+}
+
+// NewCertificateReloader loads the certificate chain and private key from the files
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	c := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate, for use as tls.Config.GetCertificate
+func (c *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.certificate, nil
+}
+
+// Reload loads the certificate again if either file has changed since it was last loaded, reporting whether it did.
+// The current certificate is kept if the files can't be loaded, e.g. when only one of them has been replaced so far.
+func (c *CertificateReloader) Reload() (bool, error) {
+	var versions [2]fileVersion
+	for i, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return false, fmt.Errorf("error reading TLS certificate, %w", err)
+		}
+		versions[i] = fileVersion{modified: info.ModTime(), size: info.Size()}
+	}
+
+	c.mu.RLock()
+	unchanged := c.certificate != nil && versions == c.versions
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("error loading TLS certificate, %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.certificate = &certificate
+	c.versions = versions
+	return true, nil
+}
+
+// Watch calls Reload every interval until stop is closed
+func (c *CertificateReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if reloaded, err := c.Reload(); err != nil {
+			log.Printf("Error reloading TLS certificate, keeping the current one, %s\n", err.Error())
+		} else if reloaded {
+			log.Printf("Reloaded TLS certificate from %s\n", c.certFile)
+		}
+	}
+}
+
+// LoadCertPool reads a bundle of PEM certificates
+func LoadCertPool(name string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle, %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("CA bundle has no PEM certificates")
+	}
+	return pool, nil
+}
+
+// ClientSubject returns the subject of the certificate the client was verified with, false if the request wasn't made
+// over TLS with a verified client certificate
+func ClientSubject(r *http.Request) (pkix.Name, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return pkix.Name{}, false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject, true
+}
+
+// tlsConfig builds the TLS configuration of the server and the reloader of its certificate, or nil if TLS is disabled
+func (c Config) tlsConfig() (*tls.Config, *CertificateReloader, error) {
+	if len(c.TLSCertFile) == 0 && len(c.TLSKeyFile) == 0 {
+		return nil, nil, nil
+	}
+
+	reloader, err := NewCertificateReloader(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+	if len(c.TLSClientCAFile) > 0 {
+		if config.ClientCAs, err = LoadCertPool(c.TLSClientCAFile); err != nil {
+			return nil, nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, reloader, nil
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and its key generated for a test
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+// newTestCertificate generates a certificate for commonName signed by parent, or self-signed if parent is nil.
+// Self-signed certificates are CAs.
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"StackPath"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// write writes the certificate and key to files in dir, setting their modification time so a reload always notices
+func (c *testCertificate) write(t *testing.T, dir string, modified time.Time) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.Nil(t, ioutil.WriteFile(certFile, c.certPEM, 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, c.keyPEM, 0600))
+	assert.Nil(t, os.Chtimes(certFile, modified, modified))
+	assert.Nil(t, os.Chtimes(keyFile, modified, modified))
+	return certFile, keyFile
+}
+
+func (c *testCertificate) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.certificate)
+	return pool
+}
+
+func TestServer_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	first := newTestCertificate(t, "first", nil)
+	certFile, keyFile := first.write(t, dir, time.Now().Add(-time.Minute))
+	config := testConfig()
+	config.TLSCertFile, config.TLSKeyFile = certFile, keyFile
+	config.TLSReloadInterval = 10 * time.Millisecond
+	srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}), config)
+	assert.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	// serverCertificate connects trusting the CAs in roots, returning the common name of the server's certificate
+	serverCertificate := func(roots *x509.CertPool) (string, error) {
+		conn, err := tls.Dial("tcp", srv.Addr(), &tls.Config{RootCAs: roots})
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	t.Run("Serves HTTPS", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: first.pool()}, ForceAttemptHTTP2: true}}
+		resp, err := client.Get("https://" + srv.Addr())
+		assert.Nil(t, err)
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			assert.Equal(t, "HTTP/2.0", string(body))
+		}
+
+		// Plain HTTP is rejected
+		resp, err = http.Get("http://" + srv.Addr())
+		assert.Nil(t, err)
+		if err == nil {
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+	t.Run("Reloads Changed Certificate", func(t *testing.T) {
+		second := newTestCertificate(t, "second", nil)
+		second.write(t, dir, time.Now())
+
+		assert.Eventually(t, func() bool {
+			name, err := serverCertificate(second.pool())
+			return err == nil && name == "second"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("Keeps Certificate When Reload Fails", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+		time.Sleep(50 * time.Millisecond)
+
+		roots := x509.NewCertPool()
+		assert.True(t, roots.AppendCertsFromPEM(mustRead(t, certFile)))
+		name, err := serverCertificate(roots)
+		assert.Nil(t, err)
+		assert.Equal(t, "second", name)
+	})
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mtls")
+	assert.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	serverCertificate := newTestCertificate(t, "server", nil)
+	clientCA := newTestCertificate(t, "clients", nil)
+	client := newTestCertificate(t, "billing", clientCA)
+	untrusted := newTestCertificate(t, "intruder", nil)
+
+	config := testConfig()
+	config.TLSCertFile, config.TLSKeyFile = serverCertificate.write(t, dir, time.Now())
+	config.TLSClientCAFile = filepath.Join(dir, "clients.pem")
+	assert.Nil(t, ioutil.WriteFile(config.TLSClientCAFile, clientCA.certPEM, 0600))
+	srv := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := ClientSubject(r)
+		assert.True(t, ok)
+		_, _ = w.Write([]byte(subject.String()))
+	}), config)
+	assert.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	get := func(certificates ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      serverCertificate.pool(),
+			Certificates: certificates,
+		}}}
+		resp, err := client.Get("https://" + srv.Addr())
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+	keyPair := func(c *testCertificate) tls.Certificate {
+		certificate, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+		assert.Nil(t, err)
+		return certificate
+	}
+
+	t.Run("Verified Client Subject", func(t *testing.T) {
+		subject, err := get(keyPair(client))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "CN=billing,O=StackPath", subject)
+	})
+	t.Run("No Client Certificate", func(t *testing.T) {
+		_, err := get()
+		assert.NotNil(t, err)
+	})
+	t.Run("Untrusted Client Certificate", func(t *testing.T) {
+		_, err := get(keyPair(untrusted))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestServer_TLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := testConfig()
+	config.TLSCertFile, config.TLSKeyFile = newTestCertificate(t, "server", nil).write(t, dir, time.Now())
+	srv := New(http.NotFoundHandler(), config)
+
+	shared, err := srv.TLSConfig()
+	assert.Nil(t, err)
+	assert.NotNil(t, shared)
+	assert.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	// Other listeners get the configuration HTTPS is served with
+	assert.Same(t, shared, srv.httpServer.TLSConfig)
+
+	t.Run("Plain HTTP", func(t *testing.T) {
+		tlsConfig, err := New(http.NotFoundHandler(), testConfig()).TLSConfig()
+
+		assert.Nil(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+}
+
+func TestServer_TLSConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	certFile, keyFile := newTestCertificate(t, "server", nil).write(t, dir, time.Now())
+
+	t.Run("Missing Certificate", func(t *testing.T) {
+		config := testConfig()
+		config.TLSCertFile, config.TLSKeyFile = filepath.Join(dir, "missing.pem"), keyFile
+
+		assert.NotNil(t, New(http.NotFoundHandler(), config).Start())
+	})
+	t.Run("Empty CA Bundle", func(t *testing.T) {
+		config := testConfig()
+		config.TLSCertFile, config.TLSKeyFile = certFile, keyFile
+		config.TLSClientCAFile = filepath.Join(dir, "empty.pem")
+		assert.Nil(t, ioutil.WriteFile(config.TLSClientCAFile, nil, 0600))
+
+		assert.NotNil(t, New(http.NotFoundHandler(), config).Start())
+	})
+}
+
+func TestClientSubject(t *testing.T) {
+	_, ok := ClientSubject(&http.Request{})
+	assert.False(t, ok)
+
+	_, ok = ClientSubject(&http.Request{TLS: &tls.ConnectionState{}})
+	assert.False(t, ok)
+}
+
+func mustRead(t *testing.T, name string) []byte {
+	contents, err := ioutil.ReadFile(name)
+	assert.Nil(t, err)
+	return contents
+}
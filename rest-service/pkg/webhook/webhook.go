@@ -0,0 +1,254 @@
+// Package webhook delivers the changes published to a models.EventBus to HTTP endpoints, signing each delivery and
+// retrying failures with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader = "X-Webhook-Event"
+	// IDHeader is the event's models.EventBus.EventID. It is the same for every attempt and endpoint so receivers can
+	// discard duplicates, and isn't reused after a restart as the epoch changes.
+	IDHeader        = "X-Webhook-ID"
+	TimestampHeader = "X-Webhook-Timestamp"
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the timestamp header, a period and the body,
+	// keyed with the endpoint's secret
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm of the signature
+const signaturePrefix = "sha256="
+
+// maxResponseSize is how much of a response body is read so the connection can be reused
+const maxResponseSize = 64 << 10
+
+// Endpoint is a URL events are POSTed to as JSON
+type Endpoint struct {
+	URL string
+	// Secret signs every delivery so the endpoint can check it came from this service
+	Secret string
+	// Events limits deliveries to these types of event, every type is delivered when empty
+	Events []models.EventType
+	// PII sends phone numbers unmasked, they are masked otherwise
+	PII bool
+}
+
+// accepts reports whether events of eventType are delivered to the endpoint
+func (e Endpoint) accepts(eventType models.EventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, accepted := range e.Events {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the endpoints events are delivered to and how failed deliveries are retried
+type Config struct {
+	Endpoints []Endpoint
+	// MaxAttempts is how many times each event is sent before giving up on it
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubling after each one up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+}
+
+// DefaultConfig returns the retry settings used unless overridden, without any endpoints
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Sign returns the signature header of a delivery of body sent at timestamp, a Unix time in seconds
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature is the signature header of a delivery of body sent at timestamp, for
+// receivers to check deliveries with. Receivers should also reject old timestamps so deliveries can't be replayed.
+func VerifySignature(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher delivers every event published to a bus to each endpoint. Each endpoint receives its events in order,
+// independently of the others, so a failing endpoint doesn't hold up deliveries to the rest.
+type Dispatcher struct {
+	bus    *models.EventBus
+	config Config
+	client *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// New creates a Dispatcher delivering the events of bus to the endpoints of config
+func New(bus *models.EventBus, config Config) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		bus:    bus,
+		config: config,
+		client: &http.Client{
+			// A redirect is treated as a failed delivery so the signed body isn't sent anywhere unexpected
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		now:    time.Now,
+	}
+}
+
+// Start delivers the events published from now on in the background, until Stop is called
+func (d *Dispatcher) Start() {
+	after := d.bus.LastID()
+	for _, endpoint := range d.config.Endpoints {
+		d.wg.Add(1)
+		go d.run(endpoint, after)
+	}
+}
+
+// Stop abandons any deliveries in progress and waits for the endpoints' goroutines to finish
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// run delivers the events after the event with ID after to endpoint, resubscribing whenever it falls behind the bus
+func (d *Dispatcher) run(endpoint Endpoint, after uint64) {
+	defer d.wg.Done()
+	for d.ctx.Err() == nil {
+		subscription, err := d.bus.Subscribe(after)
+		if errors.Is(err, models.ErrEventExpired) {
+			lastID := d.bus.LastID()
+			log.Printf("Error resuming webhook %s, events %d to %d are no longer available and weren't delivered\n", endpoint.URL, after+1, lastID)
+			after = lastID
+			continue
+		} else if err != nil {
+			log.Printf("Error subscribing webhook %s to events, %s\n", endpoint.URL, err.Error())
+			return
+		}
+		after = d.consume(endpoint, subscription, after)
+		subscription.Close()
+	}
+}
+
+// consume delivers the events of subscription until it is closed or the dispatcher is stopped, returning the ID of the
+// last event handled
+func (d *Dispatcher) consume(endpoint Endpoint, subscription *models.Subscription, after uint64) uint64 {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return after
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Fell behind while retrying, resumed from the bus's history
+				return after
+			}
+			if endpoint.accepts(event.Type) {
+				d.deliver(endpoint, event)
+			}
+			after = event.ID
+		}
+	}
+}
+
+// deliver sends event to endpoint, retrying network errors, 5xx and 429 responses up to the configured attempts
+func (d *Dispatcher) deliver(endpoint Endpoint, event models.Event) {
+	if event.Person != nil && !endpoint.PII {
+		// The bus shares the event with every subscriber so it is masked in a copy
+		person := *event.Person
+		person.PhoneNumber = models.MaskPhoneNumber(person.PhoneNumber)
+		event.Person = &person
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding event %d for webhook %s, %s\n", event.ID, endpoint.URL, err.Error())
+		return
+	}
+
+	backoff := d.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(endpoint, event, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= d.config.MaxAttempts {
+			log.Printf("Error delivering event %d to webhook %s after %d attempts, %s\n", event.ID, endpoint.URL, attempt, err.Error())
+			return
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
+
+// send makes a single attempt at delivering body, reporting whether a failure is worth retrying
+func (d *Dispatcher) send(endpoint Endpoint, event models.Event, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.Timeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error creating request, %w", err)
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(EventHeader, string(event.Type))
+	r.Header.Set(IDHeader, d.bus.EventID(event))
+	r.Header.Set(TimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(r)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("unexpected response %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/stackpath/backend-developer-tests/rest-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivery is a request received by a receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver starts an endpoint that responds with each of statuses in turn, then 204, sending every request it receives
+// to the returned channel
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan delivery) {
+	deliveries := make(chan delivery, 10)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		deliveries <- delivery{header: r.Header, body: body}
+
+		mu.Lock()
+		defer mu.Unlock()
+		status := http.StatusNoContent
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, deliveries
+}
+
+// next returns the next delivery, failing if it doesn't arrive promptly
+func next(t *testing.T, deliveries <-chan delivery) delivery {
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("no delivery received")
+		return delivery{}
+	}
+}
+
+// idHeader returns the IDHeader of deliveries of the event with the ID published to bus
+func idHeader(bus *models.EventBus, eventID uint64) string {
+	return bus.EventID(models.Event{ID: eventID})
+}
+
+// start runs a dispatcher delivering to endpoints with short backoffs until the test ends
+func start(t *testing.T, bus *models.EventBus, endpoints ...Endpoint) *Dispatcher {
+	config := DefaultConfig()
+	config.Endpoints = endpoints
+	config.InitialBackoff = time.Millisecond
+	config.MaxBackoff = 5 * time.Millisecond
+	config.MaxAttempts = 3
+	dispatcher := New(bus, config)
+	dispatcher.now = func() time.Time { return time.Unix(1622548800, 0) }
+	dispatcher.Start()
+	t.Cleanup(dispatcher.Stop)
+	return dispatcher
+}
+
+func TestDispatcher(t *testing.T) {
+	person := models.SamplePeople()[0]
+
+	t.Run("Signed Delivery", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		server, deliveries := receiver(t)
+		start(t, bus, Endpoint{URL: server.URL, Secret: "s3cret"})
+
+		bus.Publish(models.EventPersonCreated, person.ID, person)
+		d := next(t, deliveries)
+
+		assert.Equal(t, "application/json", d.header.Get("Content-Type"))
+		assert.Equal(t, "person.created", d.header.Get(EventHeader))
+		assert.Equal(t, bus.Epoch().String()+":1", d.header.Get(IDHeader))
+		assert.Equal(t, "1622548800", d.header.Get(TimestampHeader))
+		assert.True(t, VerifySignature("s3cret", d.header.Get(TimestampHeader), d.body, d.header.Get(SignatureHeader)))
+
+		var event models.Event
+		assert.Nil(t, json.Unmarshal(d.body, &event))
+		assert.Equal(t, uint64(1), event.ID)
+		assert.Equal(t, person.ID, event.PersonID)
+		assert.Equal(t, person.FirstName, event.Person.FirstName)
+		assert.Equal(t, models.MaskPhoneNumber(person.PhoneNumber), event.Person.PhoneNumber)
+	})
+	t.Run("PII", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		server, deliveries := receiver(t)
+		start(t, bus, Endpoint{URL: server.URL, Secret: "s3cret", PII: true})
+
+		bus.Publish(models.EventPersonUpdated, person.ID, person)
+		var event models.Event
+		assert.Nil(t, json.Unmarshal(next(t, deliveries).body, &event))
+
+		assert.Equal(t, person.PhoneNumber, event.Person.PhoneNumber)
+	})
+	t.Run("Retries Server Errors", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		server, deliveries := receiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		start(t, bus, Endpoint{URL: server.URL, Secret: "s3cret"})
+
+		bus.Publish(models.EventPersonCreated, person.ID, person)
+		bus.Publish(models.EventPersonDeleted, person.ID, nil)
+
+		// The first event is retried until it succeeds before the second is sent
+		for _, eventID := range []uint64{1, 1, 1, 2} {
+			assert.Equal(t, idHeader(bus, eventID), next(t, deliveries).header.Get(IDHeader))
+		}
+	})
+	t.Run("Gives Up", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		server, deliveries := receiver(t, http.StatusBadRequest, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		start(t, bus, Endpoint{URL: server.URL, Secret: "s3cret"})
+
+		bus.Publish(models.EventPersonCreated, person.ID, person)
+		bus.Publish(models.EventPersonUpdated, person.ID, person)
+		bus.Publish(models.EventPersonDeleted, person.ID, nil)
+
+		// Client errors aren't retried, server errors are until the maximum attempts
+		for _, eventID := range []uint64{1, 2, 2, 2, 3} {
+			assert.Equal(t, idHeader(bus, eventID), next(t, deliveries).header.Get(IDHeader))
+		}
+	})
+	t.Run("Event Filter", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		deletes, deleteDeliveries := receiver(t)
+		all, allDeliveries := receiver(t)
+		start(t, bus, Endpoint{URL: deletes.URL, Secret: "s3cret", Events: []models.EventType{models.EventPersonDeleted}},
+			Endpoint{URL: all.URL, Secret: "s3cret"})
+
+		bus.Publish(models.EventPersonCreated, person.ID, person)
+		bus.Publish(models.EventPersonDeleted, person.ID, nil)
+
+		assert.Equal(t, idHeader(bus, 2), next(t, deleteDeliveries).header.Get(IDHeader))
+		assert.Equal(t, idHeader(bus, 1), next(t, allDeliveries).header.Get(IDHeader))
+		assert.Equal(t, idHeader(bus, 2), next(t, allDeliveries).header.Get(IDHeader))
+	})
+	t.Run("Resumes After Falling Behind", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		release := make(chan struct{})
+		var received []string
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, r.Header.Get(IDHeader))
+		}))
+		defer server.Close()
+		start(t, bus, Endpoint{URL: server.URL, Secret: "s3cret"})
+
+		// More events than a subscriber can buffer are published while the first delivery is held up
+		const events = 200
+		for i := 0; i < events; i++ {
+			bus.Publish(models.EventPersonUpdated, person.ID, person)
+		}
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(received) == events
+		}, 5*time.Second, 10*time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, idHeader(bus, 1), received[0])
+		assert.Equal(t, idHeader(bus, events), received[events-1])
+	})
+	t.Run("IDs Not Reused After Restart", func(t *testing.T) {
+		first, second := models.NewEventBus(models.DefaultEventHistory), models.NewEventBus(models.DefaultEventHistory)
+		firstServer, firstDeliveries := receiver(t)
+		secondServer, secondDeliveries := receiver(t)
+		start(t, first, Endpoint{URL: firstServer.URL, Secret: "s3cret"})
+		start(t, second, Endpoint{URL: secondServer.URL, Secret: "s3cret"})
+
+		first.Publish(models.EventPersonCreated, person.ID, person)
+		second.Publish(models.EventPersonCreated, person.ID, person)
+
+		assert.NotEqual(t, next(t, firstDeliveries).header.Get(IDHeader), next(t, secondDeliveries).header.Get(IDHeader))
+	})
+	t.Run("Stop Abandons Retries", func(t *testing.T) {
+		bus := models.NewEventBus(models.DefaultEventHistory)
+		server, deliveries := receiver(t, http.StatusInternalServerError)
+		config := DefaultConfig()
+		config.Endpoints = []Endpoint{{URL: server.URL, Secret: "s3cret"}}
+		config.InitialBackoff = time.Hour
+		dispatcher := New(bus, config)
+		dispatcher.Start()
+
+		bus.Publish(models.EventPersonCreated, person.ID, person)
+		next(t, deliveries)
+		stopped := make(chan struct{})
+		go func() {
+			dispatcher.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(2 * time.Second):
+			t.Fatal("dispatcher didn't stop")
+		}
+	})
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := Sign("s3cret", "1622548800", body)
+
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.True(t, VerifySignature("s3cret", "1622548800", body, signature))
+	assert.False(t, VerifySignature("wrong", "1622548800", body, signature))
+	assert.False(t, VerifySignature("s3cret", "1622548801", body, signature))
+	assert.False(t, VerifySignature("s3cret", "1622548800", []byte(`{"id":2}`), signature))
+	assert.False(t, VerifySignature("s3cret", "1622548800", body, ""))
+}